@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hexToUTF8 解析十六进制字符串, 可以带 0x、\x 前缀和空白、逗号、冒号分隔
+func hexToUTF8(hexStr string) (string, error) {
+	hexBytes, err := parseHex(hexStr)
+	if err != nil {
+		return "", err
+	}
+
+	utf8Str := string(hexBytes)
+	return utf8Str, nil
+}
+
+func parseHex(hexStr string) ([]byte, error) {
+	cleaned := strings.NewReplacer(
+		"0x", "", "0X", "", `\x`, "",
+		" ", "", "\t", "", "\n", "", "\r", "",
+		",", "", ":", "", "{", "", "}", "",
+	).Replace(hexStr)
+	if len(cleaned)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hex digits (%d)", len(cleaned))
+	}
+	return hex.DecodeString(cleaned)
+}
+
+// writeGoLiteral 输出与 ListData、UserDat 相同格式的 []byte 字面量
+func writeGoLiteral(w io.Writer, name string, data []byte, perLine int) {
+	indent := ""
+	if name != "" {
+		fmt.Fprintf(w, "var %s = []byte{\n", name)
+		indent = "\t"
+	}
+	for i := 0; i < len(data); i += perLine {
+		end := min(i+perLine, len(data))
+		parts := make([]string, 0, end-i)
+		for _, b := range data[i:end] {
+			parts = append(parts, fmt.Sprintf("0x%02x", b))
+		}
+		fmt.Fprintf(w, "%s%s,\n", indent, strings.Join(parts, ", "))
+	}
+	if name != "" {
+		fmt.Fprintln(w, "}")
+	}
+}
+
+// writeHexDump 输出与 hexdump -C 相同的布局
+func writeHexDump(w io.Writer, data []byte) {
+	var previous []byte
+	skipping := false
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:min(off+16, len(data))]
+		if previous != nil && len(line) == 16 && string(line) == string(previous) {
+			if !skipping {
+				fmt.Fprintln(w, "*")
+				skipping = true
+			}
+			continue
+		}
+		skipping = false
+		previous = line
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%08x  ", off)
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				fmt.Fprintf(&b, "%02x ", line[i])
+			} else {
+				b.WriteString("   ")
+			}
+			if i == 7 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(" |")
+		for _, c := range line {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteString("|")
+		fmt.Fprintln(w, b.String())
+	}
+	if len(data) > 0 {
+		fmt.Fprintf(w, "%08x\n", len(data))
+	}
+}
@@ -0,0 +1,7 @@
+module 16xtotext
+
+go 1.22.2
+
+require router v0.0.0
+
+replace router => ../router_program
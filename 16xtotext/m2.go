@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"router/pkg/winbox"
+)
+
+// decodeM2 解析一个或多个 winbox 报文, 或者一个以 "M2" 开头的裸消息
+func decodeM2(data []byte) ([]string, error) {
+	if bytes.HasPrefix(data, []byte("M2")) {
+		return []string{winbox.Decode(data).SerializeToJson()}, nil
+	}
+
+	var lines []string
+	for len(data) > 0 {
+		frame, n, err := winbox.SplitFrame(data)
+		if err == winbox.ErrIncomplete {
+			return lines, fmt.Errorf("%d trailing bytes do not form a complete frame", len(data))
+		}
+		if err != nil {
+			return lines, err
+		}
+		if !bytes.HasPrefix(frame.Payload, []byte("M2")) {
+			return lines, errors.New("frame payload does not start with M2")
+		}
+		lines = append(lines, fmt.Sprintf("handle=0x%02x %s", frame.Handle, winbox.Decode(frame.Payload).SerializeToJson()))
+		data = data[n:]
+	}
+	return lines, nil
+}
+
+// encodeM2 把每行一个的文本格式消息编码为报文, 空行和 # 开头的行被忽略
+func encodeM2(text string, handle byte, bare bool) ([]byte, error) {
+	var out []byte
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		msg, err := winbox.ParseText(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if bare {
+			out = append(out, msg.Marshal()...)
+			continue
+		}
+		frame, err := winbox.EncodeFrame(handle, msg.Marshal())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out = append(out, frame...)
+	}
+	return out, nil
+}
+
+// looksLikeHex 判断输入是否为十六进制文本而不是二进制数据
+func looksLikeHex(data []byte) bool {
+	for _, c := range data {
+		if !strings.ContainsRune("0123456789abcdefABCDEFxX\\ ,:{}\t\r\n", rune(c)) {
+			return false
+		}
+	}
+	return len(bytes.TrimSpace(data)) > 0
+}
+
+func runM2Decode(args []string) error {
+	var in input
+	fs := newFlagSet("m2dec", &in, false)
+	rawInput := fs.Bool("r", false, "input is raw binary (detected automatically by default)")
+	fs.Parse(args)
+
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	if !*rawInput && looksLikeHex(data) {
+		if data, err = parseHex(string(data)); err != nil {
+			return err
+		}
+	}
+
+	lines, err := decodeM2(data)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return err
+}
+
+func runM2Encode(args []string) error {
+	var in input
+	fs := newFlagSet("m2enc", &in, false)
+	handle := fs.Uint("handle", uint(winbox.HandleDefault), "frame handle byte")
+	bare := fs.Bool("bare", false, "emit the bare M2 message without the frame header")
+	asHex := fs.Bool("hex", false, "print hex instead of writing binary to stdout")
+	fs.Parse(args)
+
+	if *handle > 0xff {
+		return fmt.Errorf("-handle must fit in one byte")
+	}
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	out, err := encodeM2(string(data), byte(*handle), *bare)
+	if err != nil {
+		return err
+	}
+	if *asHex {
+		fmt.Printf("%x\n", out)
+		return nil
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
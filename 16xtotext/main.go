@@ -1,197 +1,156 @@
 package main
 
 import (
-	"encoding/hex"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
-func hexToUTF8(hexStr string) (string, error) {
-	hexBytes, err := hex.DecodeString(hexStr)
-	if err != nil {
-		return "", err
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"hex2text", "decode hex into text", runHexToText},
+	{"text2hex", "encode text as hex", runTextToHex},
+	{"golit", "print bytes as a Go []byte literal", runGoLiteral},
+	{"dump", "print bytes in hexdump -C layout", runDump},
+	{"m2dec", "decode a winbox frame or bare M2 message", runM2Decode},
+	{"m2enc", "encode M2 text notation into a winbox frame", runM2Encode},
+	{"manifest", "convert the winbox list manifest to and from JSON", runManifest},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [input ...]\n\ncommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\ninput is taken from the arguments, from -f file, or from stdin.\n")
+	fmt.Fprintf(os.Stderr, "run '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if os.Args[1] != "-h" && os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+	}
+	usage()
+	os.Exit(2)
+}
 
-	utf8Str := string(hexBytes)
-	return utf8Str, nil
+// input 描述一个子命令的输入来源
+type input struct {
+	file  string
+	isHex bool
 }
 
-func hexByteToString() {
-	hexByte := []byte{
-		0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x31, 0x36, 0x34, 0x35, 0x36,
-		0x32, 0x38, 0x37, 0x33, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20,
-		0x31, 0x31, 0x34, 0x39, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20,
-		0x22, 0x61, 0x64, 0x76, 0x74, 0x6f, 0x6f, 0x6c, 0x2e, 0x6a, 0x67, 0x22,
-		0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x61,
-		0x64, 0x76, 0x74, 0x6f, 0x6f, 0x6c, 0x2d, 0x66, 0x63, 0x31, 0x39, 0x33,
-		0x32, 0x66, 0x36, 0x38, 0x30, 0x39, 0x65, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36,
-		0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20,
-		0x63, 0x72, 0x63, 0x3a, 0x20, 0x33, 0x36, 0x37, 0x30, 0x36, 0x38, 0x39,
-		0x34, 0x38, 0x38, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x33,
-		0x31, 0x31, 0x39, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22,
-		0x64, 0x68, 0x63, 0x70, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e,
-		0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x64, 0x68, 0x63, 0x70, 0x2d,
-		0x37, 0x35, 0x66, 0x36, 0x36, 0x39, 0x39, 0x34, 0x62, 0x61, 0x34, 0x31,
-		0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
-		0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20,
-		0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x31, 0x31,
-		0x38, 0x33, 0x37, 0x37, 0x39, 0x38, 0x33, 0x34, 0x2c, 0x20, 0x73, 0x69,
-		0x7a, 0x65, 0x3a, 0x20, 0x31, 0x32, 0x34, 0x38, 0x39, 0x2c, 0x20, 0x6e,
-		0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x64, 0x75, 0x64, 0x65, 0x2e, 0x6a,
-		0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20,
-		0x22, 0x64, 0x75, 0x64, 0x65, 0x2d, 0x36, 0x35, 0x66, 0x31, 0x38, 0x66,
-		0x61, 0x65, 0x64, 0x36, 0x34, 0x39, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20,
-		0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e,
-		0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63,
-		0x72, 0x63, 0x3a, 0x20, 0x34, 0x34, 0x34, 0x37, 0x38, 0x32, 0x37, 0x39,
-		0x34, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x34, 0x33, 0x33,
-		0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x67, 0x70, 0x73,
-		0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65,
-		0x3a, 0x20, 0x22, 0x67, 0x70, 0x73, 0x2d, 0x32, 0x31, 0x66, 0x61, 0x38,
-		0x31, 0x34, 0x32, 0x33, 0x61, 0x35, 0x65, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36,
-		0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20,
-		0x63, 0x72, 0x63, 0x3a, 0x20, 0x33, 0x35, 0x32, 0x39, 0x32, 0x33, 0x36,
-		0x37, 0x37, 0x34, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x34,
-		0x31, 0x30, 0x39, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22,
-		0x68, 0x6f, 0x74, 0x73, 0x70, 0x6f, 0x74, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x68, 0x6f,
-		0x74, 0x73, 0x70, 0x6f, 0x74, 0x2d, 0x63, 0x63, 0x63, 0x33, 0x39, 0x61,
-		0x32, 0x38, 0x31, 0x39, 0x62, 0x66, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20,
-		0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e,
-		0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63,
-		0x72, 0x63, 0x3a, 0x20, 0x31, 0x30, 0x39, 0x33, 0x39, 0x37, 0x30, 0x39,
-		0x36, 0x35, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x32, 0x32,
-		0x34, 0x35, 0x31, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22,
-		0x69, 0x63, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x6e, 0x67, 0x22, 0x2c, 0x20,
-		0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e,
-		0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63,
-		0x72, 0x63, 0x3a, 0x20, 0x31, 0x36, 0x33, 0x36, 0x33, 0x37, 0x31, 0x39,
-		0x31, 0x36, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x36, 0x34,
-		0x33, 0x30, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x69,
-		0x70, 0x76, 0x36, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69,
-		0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x69, 0x70, 0x76, 0x36, 0x2d, 0x65,
-		0x32, 0x62, 0x31, 0x30, 0x66, 0x31, 0x36, 0x66, 0x33, 0x36, 0x61, 0x2e,
-		0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-		0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d,
-		0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x31, 0x36, 0x35,
-		0x34, 0x36, 0x31, 0x35, 0x33, 0x32, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65,
-		0x3a, 0x20, 0x31, 0x34, 0x37, 0x33, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65,
-		0x3a, 0x20, 0x22, 0x6b, 0x76, 0x6d, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20,
-		0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x6b, 0x76, 0x6d,
-		0x2d, 0x36, 0x65, 0x31, 0x30, 0x32, 0x39, 0x34, 0x37, 0x30, 0x61, 0x34,
-		0x34, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69,
-		0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22,
-		0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x36,
-		0x36, 0x37, 0x38, 0x35, 0x37, 0x32, 0x30, 0x39, 0x2c, 0x20, 0x73, 0x69,
-		0x7a, 0x65, 0x3a, 0x20, 0x34, 0x35, 0x35, 0x2c, 0x20, 0x6e, 0x61, 0x6d,
-		0x65, 0x3a, 0x20, 0x22, 0x6c, 0x63, 0x64, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x6c, 0x63,
-		0x64, 0x2d, 0x33, 0x30, 0x61, 0x37, 0x34, 0x30, 0x62, 0x66, 0x35, 0x33,
-		0x37, 0x35, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73,
-		0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34,
-		0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20,
-		0x31, 0x30, 0x32, 0x31, 0x35, 0x31, 0x39, 0x30, 0x33, 0x38, 0x2c, 0x20,
-		0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x33, 0x36, 0x32, 0x38, 0x2c, 0x20,
-		0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x6d, 0x70, 0x6c, 0x73, 0x2e,
-		0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a,
-		0x20, 0x22, 0x6d, 0x70, 0x6c, 0x73, 0x2d, 0x36, 0x63, 0x63, 0x61, 0x36,
-		0x36, 0x63, 0x33, 0x66, 0x31, 0x37, 0x30, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36,
-		0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20,
-		0x63, 0x72, 0x63, 0x3a, 0x20, 0x33, 0x33, 0x32, 0x35, 0x34, 0x32, 0x37,
-		0x32, 0x30, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x34, 0x35,
-		0x37, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x6e, 0x74,
-		0x70, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75,
-		0x65, 0x3a, 0x20, 0x22, 0x6e, 0x74, 0x70, 0x2d, 0x34, 0x31, 0x32, 0x65,
-		0x38, 0x30, 0x65, 0x30, 0x36, 0x66, 0x38, 0x38, 0x2e, 0x6a, 0x67, 0x22,
-		0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22,
-		0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b,
-		0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x32, 0x38, 0x37, 0x30, 0x37, 0x36,
-		0x32, 0x38, 0x36, 0x33, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20,
-		0x32, 0x33, 0x34, 0x32, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20,
-		0x22, 0x70, 0x69, 0x6d, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e,
-		0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x70, 0x69, 0x6d, 0x2d, 0x66,
-		0x61, 0x63, 0x34, 0x63, 0x65, 0x39, 0x65, 0x64, 0x64, 0x34, 0x34, 0x2e,
-		0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-		0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d,
-		0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x32, 0x38, 0x34,
-		0x39, 0x34, 0x36, 0x36, 0x35, 0x30, 0x39, 0x2c, 0x20, 0x73, 0x69, 0x7a,
-		0x65, 0x3a, 0x20, 0x34, 0x34, 0x30, 0x38, 0x2c, 0x20, 0x6e, 0x61, 0x6d,
-		0x65, 0x3a, 0x20, 0x22, 0x70, 0x70, 0x70, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x70, 0x70,
-		0x70, 0x2d, 0x66, 0x36, 0x39, 0x39, 0x30, 0x62, 0x37, 0x37, 0x39, 0x36,
-		0x38, 0x32, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73,
-		0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34,
-		0x22, 0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20,
-		0x32, 0x37, 0x34, 0x32, 0x39, 0x35, 0x31, 0x30, 0x35, 0x2c, 0x20, 0x73,
-		0x69, 0x7a, 0x65, 0x3a, 0x20, 0x36, 0x33, 0x35, 0x33, 0x33, 0x2c, 0x20,
-		0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x72, 0x6f, 0x74, 0x65, 0x72,
-		0x6f, 0x73, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71,
-		0x75, 0x65, 0x3a, 0x20, 0x22, 0x72, 0x6f, 0x74, 0x65, 0x72, 0x6f, 0x73,
-		0x2d, 0x63, 0x38, 0x36, 0x37, 0x35, 0x33, 0x33, 0x33, 0x61, 0x33, 0x66,
-		0x39, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69,
-		0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22,
-		0x20, 0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x32,
-		0x39, 0x31, 0x31, 0x30, 0x39, 0x31, 0x38, 0x30, 0x36, 0x2c, 0x20, 0x73,
-		0x69, 0x7a, 0x65, 0x3a, 0x20, 0x38, 0x32, 0x34, 0x30, 0x2c, 0x20, 0x6e,
-		0x61, 0x6d, 0x65, 0x3a, 0x20, 0x22, 0x72, 0x6f, 0x74, 0x69, 0x6e, 0x67,
-		0x34, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75,
-		0x65, 0x3a, 0x20, 0x22, 0x72, 0x6f, 0x74, 0x69, 0x6e, 0x67, 0x34, 0x2d,
-		0x32, 0x63, 0x61, 0x62, 0x65, 0x35, 0x39, 0x31, 0x38, 0x31, 0x65, 0x62,
-		0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
-		0x6e, 0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20,
-		0x7d, 0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x32, 0x37,
-		0x38, 0x34, 0x38, 0x37, 0x30, 0x38, 0x33, 0x37, 0x2c, 0x20, 0x73, 0x69,
-		0x7a, 0x65, 0x3a, 0x20, 0x33, 0x34, 0x38, 0x33, 0x2c, 0x20, 0x6e, 0x61,
-		0x6d, 0x65, 0x3a, 0x20, 0x22, 0x73, 0x65, 0x63, 0x75, 0x72, 0x65, 0x2e,
-		0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a,
-		0x20, 0x22, 0x73, 0x65, 0x63, 0x75, 0x72, 0x65, 0x2d, 0x35, 0x38, 0x39,
-		0x66, 0x32, 0x65, 0x38, 0x31, 0x34, 0x66, 0x35, 0x34, 0x2e, 0x6a, 0x67,
-		0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20,
-		0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a,
-		0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x31, 0x36, 0x31, 0x37, 0x39,
-		0x33, 0x38, 0x32, 0x33, 0x36, 0x2c, 0x20, 0x73, 0x69, 0x7a, 0x65, 0x3a,
-		0x20, 0x37, 0x36, 0x35, 0x2c, 0x20, 0x6e, 0x61, 0x6d, 0x65, 0x3a, 0x20,
-		0x22, 0x75, 0x70, 0x73, 0x2e, 0x6a, 0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e,
-		0x69, 0x71, 0x75, 0x65, 0x3a, 0x20, 0x22, 0x75, 0x70, 0x73, 0x2d, 0x65,
-		0x32, 0x39, 0x36, 0x38, 0x33, 0x63, 0x38, 0x64, 0x34, 0x39, 0x32, 0x2e,
-		0x6a, 0x67, 0x22, 0x2c, 0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-		0x3a, 0x20, 0x22, 0x36, 0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d,
-		0x2c, 0x0a, 0x7b, 0x20, 0x63, 0x72, 0x63, 0x3a, 0x20, 0x32, 0x36, 0x38,
-		0x32, 0x39, 0x37, 0x39, 0x36, 0x33, 0x34, 0x2c, 0x20, 0x73, 0x69, 0x7a,
-		0x65, 0x3a, 0x20, 0x31, 0x36, 0x31, 0x37, 0x38, 0x2c, 0x20, 0x6e, 0x61,
-		0x6d, 0x65, 0x3a, 0x20, 0x22, 0x77, 0x6c, 0x61, 0x6e, 0x36, 0x2e, 0x6a,
-		0x67, 0x22, 0x2c, 0x20, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x3a, 0x20,
-		0x22, 0x77, 0x6c, 0x61, 0x6e, 0x36, 0x2d, 0x38, 0x37, 0x65, 0x64, 0x32,
-		0x61, 0x63, 0x66, 0x36, 0x33, 0x65, 0x65, 0x2e, 0x6a, 0x67, 0x22, 0x2c,
-		0x20, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x22, 0x36,
-		0x2e, 0x34, 0x31, 0x2e, 0x34, 0x22, 0x20, 0x7d, 0x2c, 0x0a,
+func newFlagSet(name string, in *input, hexFlag bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&in.file, "f", "", "read input from file ('-' for stdin)")
+	if hexFlag {
+		fs.BoolVar(&in.isHex, "x", false, "input is hex text rather than raw bytes")
+	}
+	return fs
+}
+
+// read 按 参数 > -f 文件 > 标准输入 的顺序读取输入
+func (in *input) read(args []string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case len(args) > 0:
+		data = []byte(strings.Join(args, " "))
+	case in.file != "" && in.file != "-":
+		data, err = os.ReadFile(in.file)
+	default:
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return nil, err
 	}
+	if in.isHex {
+		return parseHex(string(data))
+	}
+	return data, nil
+}
+
+func runHexToText(args []string) error {
+	var in input
+	fs := newFlagSet("hex2text", &in, false)
+	fs.Parse(args)
 
-	hexstring := hex.EncodeToString(hexByte)
-	utf8Str, err := hexToUTF8(hexstring)
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	utf8Str, err := hexToUTF8(string(data))
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return err
 	}
+	fmt.Print(utf8Str)
+	return nil
+}
+
+func runTextToHex(args []string) error {
+	var in input
+	fs := newFlagSet("text2hex", &in, false)
+	trim := fs.Bool("t", false, "trim the trailing newline of the input")
+	fs.Parse(args)
 
-	fmt.Println("UTF-8 String:", utf8Str)
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *trim {
+		data = []byte(strings.TrimRight(string(data), "\r\n"))
+	}
+	fmt.Printf("%x\n", data)
+	return nil
 }
 
-func StringToHexByte() {
-	stringValue := "616476746f6f6c2e646c6c3a362e34392e3135646863702e646c6c3a362e34392e3135647564652e646c6c3a362e34392e3135686f7473706f742e646c6c3a362e34392e31356d706c732e646c6c3a362e34392e31357070702e646c6c3a362e34392e3135726f7465726f732e646c6c3a362e34392e3135726f74696e67342e646c6c3a362e34392e31357365637572652e646c6c3a362e34392e313573797374656d2e646c6c3a362e34392e31357570732e646c6c3a362e34392e3135776c616e362e646c6c3a362e34392e3135"
-	byteValue, _ := hex.DecodeString(stringValue)
-	fmt.Println(byteValue)
-	for i, v := range byteValue {
-		fmt.Printf("0x%2x,", v)
-		if i%12 == 0 && i != 0 {
-			fmt.Print("\n")
-		}
+func runGoLiteral(args []string) error {
+	var in input
+	fs := newFlagSet("golit", &in, true)
+	name := fs.String("n", "", "wrap the literal in 'var <name> = []byte{...}'")
+	perLine := fs.Int("w", 12, "bytes per line")
+	fs.Parse(args)
+
+	if *perLine <= 0 {
+		return fmt.Errorf("-w must be positive")
+	}
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
 	}
+	writeGoLiteral(os.Stdout, *name, data, *perLine)
+	return nil
 }
 
-func main() {
-	StringToHexByte()
-	//hexByteToString()
+func runDump(args []string) error {
+	var in input
+	fs := newFlagSet("dump", &in, true)
+	fs.Parse(args)
+
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	writeHexDump(os.Stdout, data)
+	return nil
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"router/pkg/winbox"
+)
+
+// parseManifestInput 自动识别 JSON 数组或 list 格式
+func parseManifestInput(data []byte) (winbox.Manifest, bool, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var m winbox.Manifest
+		err := json.Unmarshal(trimmed, &m)
+		return m, true, err
+	}
+	m, err := winbox.ParseManifest(data)
+	return m, false, err
+}
+
+func runManifest(args []string) error {
+	var in input
+	fs := newFlagSet("manifest", &in, true)
+	to := fs.String("to", "", "output format: json or list (default: the other format)")
+	check := fs.String("check", "", "verify size and crc32 against the files in this directory")
+	fs.Parse(args)
+
+	data, err := in.read(fs.Args())
+	if err != nil {
+		return err
+	}
+	m, isJSON, err := parseManifestInput(data)
+	if err != nil {
+		return err
+	}
+
+	if *check != "" {
+		errs := m.Validate(*check)
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d entries do not match", len(errs), len(m))
+		}
+		fmt.Fprintf(os.Stderr, "%d entries ok\n", len(m))
+		if *to == "" {
+			return nil
+		}
+	}
+
+	format := *to
+	if format == "" {
+		format = "json"
+		if isJSON {
+			format = "list"
+		}
+	}
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	case "list":
+		os.Stdout.Write(m.Bytes())
+	default:
+		return errors.New("-to must be json or list")
+	}
+	return nil
+}
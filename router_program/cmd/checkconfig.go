@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"router/internal/config"
+)
+
+// checkConfig 实现 check-config 子命令, 返回进程退出码
+func checkConfig(args []string) int {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	path := fs.String("c", "", "config file path (may also be given as an argument)")
+	write := fs.Bool("w", false, "rewrite the file in the current schema version after a successful check")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s check-config [-w] [-c] config\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *path == "" && fs.NArg() == 1 {
+		*path = fs.Arg(0)
+	}
+	if *path == "" || fs.NArg() > 1 || fs.NArg() == 1 && fs.Arg(0) != *path {
+		fs.Usage()
+		return 2
+	}
+
+	c, err := config.Load(*path)
+	if err != nil {
+		var issues config.Issues
+		if !errors.As(err, &issues) {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *path, issue)
+		}
+		return 1
+	}
+	for _, issue := range c.Warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *path, issue)
+	}
+
+	if *write {
+		if err := rewriteConfig(*path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%s: rewritten as version %d\n", *path, config.Version)
+		return 0
+	}
+	fmt.Printf("%s: ok\n", *path)
+	return 0
+}
+
+// rewriteConfig 把配置文件迁移到当前版本, 只改写文件中已有的字段
+// 先写入临时文件再替换, 避免配置文件只写了一半
+func rewriteConfig(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content, err = config.Upgrade(content)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"router/internal/log"
+	"router/internal/metrics"
+)
+
+// serveMetrics 在 addr 上输出 Prometheus 指标
+func serveMetrics(addr, path string, m *metrics.Metrics) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, m.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Slog.Error("指标服务异常退出", "err", err.Error())
+		}
+	}()
+	log.Slog.Info("Prometheus 指标已启动", "addr", l.Addr().String(), "path", path)
+	return srv, nil
+}
+
+// serveAdmin 在 addr 上提供管理接口, addr 为 unix:/path 时只允许当前用户访问
+func serveAdmin(addr string, h http.Handler) (*http.Server, error) {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+		// 删除上次运行留下的 socket
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := os.Chmod(addr, 0o600); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Slog.Error("管理接口异常退出", "err", err.Error())
+		}
+	}()
+	log.Slog.Info("管理接口已启动", "addr", l.Addr().String())
+	return srv, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+func dropPrivileges(name string, paths []string) error {
+	return errors.New("-user is not supported on this platform")
+}
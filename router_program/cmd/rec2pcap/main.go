@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"router/internal/pcap"
+	"router/internal/record"
+	"sort"
+	"time"
+)
+
+type packet struct {
+	ts    time.Time
+	frame []byte
+}
+
+func main() {
+	output := flag.String("o", "sessions.pcap", "output pcap file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-o out.pcap] recording.jsonl|dir ...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var packets []packet
+	for _, path := range expand(flag.Args()) {
+		p, err := convert(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			continue
+		}
+		packets = append(packets, p...)
+	}
+	// 多个会话按原始时间交织
+	sort.SliceStable(packets, func(i, j int) bool {
+		return packets[i].ts.Before(packets[j].ts)
+	})
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	w, err := pcap.NewWriter(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, p := range packets {
+		if err := w.WritePacket(p.ts, p.frame); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("wrote %d packets to %s\n", len(packets), *output)
+}
+
+// expand 把目录展开为其中的 .jsonl 文件
+func expand(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err == nil && info.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(arg, "*.jsonl"))
+			paths = append(paths, matches...)
+			continue
+		}
+		paths = append(paths, arg)
+	}
+	return paths
+}
+
+func convert(path string) ([]packet, error) {
+	entries, err := record.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	session := entries[0]
+	client, err := netip.ParseAddrPort(session.Client)
+	if err != nil {
+		return nil, fmt.Errorf("client address: %w", err)
+	}
+	server, err := netip.ParseAddrPort(session.Listener)
+	if err != nil {
+		return nil, fmt.Errorf("listener address: %w", err)
+	}
+
+	flow := pcap.NewFlow(client, server)
+	var packets []packet
+	add := func(ts time.Time, frames [][]byte) {
+		for _, frame := range frames {
+			packets = append(packets, packet{ts: ts, frame: frame})
+		}
+	}
+
+	add(session.Time, flow.Handshake())
+	last := session.Time
+	for _, e := range entries[1:] {
+		if e.Type != record.TypeData {
+			continue
+		}
+		add(e.Time, flow.Data(e.Dir == record.DirIn, e.Data))
+		last = e.Time
+	}
+	add(last, flow.Close())
+	return packets, nil
+}
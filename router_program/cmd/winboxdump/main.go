@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"router/internal/pcap"
+	"router/pkg/winbox"
+	"time"
+)
+
+type direction struct {
+	client     netip.AddrPort
+	server     netip.AddrPort
+	fromClient bool
+}
+
+func main() {
+	port := flag.Uint("p", 8291, "winbox server port")
+	showHex := flag.Bool("x", false, "also print the hex of every message")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-p port] [-x] capture.pcap|capture.pcapng\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *port > 0xffff {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	reader, err := pcap.NewReader(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// 每个方向各自缓存尚未组成完整报文的数据
+	buffers := make(map[direction][]byte)
+	assembler := pcap.NewAssembler(uint16(*port), func(c pcap.Chunk) {
+		dir := direction{client: c.Client, server: c.Server, fromClient: c.FromClient}
+		buf := append(buffers[dir], c.Data...)
+		for {
+			frame, n, err := winbox.SplitFrame(buf)
+			if err == winbox.ErrIncomplete {
+				break
+			}
+			buf = buf[n:]
+			if err != nil {
+				printLine(c.Time, dir, "invalid frame header, skipped")
+				continue
+			}
+			printFrame(c.Time, dir, frame, *showHex)
+		}
+		buffers[dir] = buf
+	})
+
+	for {
+		packet, err := reader.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			break
+		}
+		if seg, ok := pcap.DecodeTCP(packet.LinkType, packet.Data); ok {
+			assembler.Add(packet.Time, seg)
+		}
+	}
+	assembler.Flush()
+
+	for dir, buf := range buffers {
+		if len(buf) > 0 {
+			printLine(time.Time{}, dir, fmt.Sprintf("%d trailing bytes without a complete frame", len(buf)))
+		}
+	}
+}
+
+func printFrame(ts time.Time, dir direction, frame winbox.Frame, showHex bool) {
+	msg := winbox.Decode(frame.Payload)
+	printLine(ts, dir, fmt.Sprintf("handle=0x%02x %s", frame.Handle, msg.SerializeToJson()))
+	if showHex {
+		fmt.Println(hex.EncodeToString(frame.Payload))
+	}
+}
+
+func printLine(ts time.Time, dir direction, text string) {
+	arrow := fmt.Sprintf("%s -> %s", dir.client, dir.server)
+	if !dir.fromClient {
+		arrow = fmt.Sprintf("%s <- %s", dir.client, dir.server)
+	}
+	stamp := "-"
+	if !ts.IsZero() {
+		stamp = ts.Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+	fmt.Printf("%s %s %s\n", stamp, arrow, text)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"router/pkg/winbox"
+)
+
+// 常用字段的别名, 既用于 send 命令也用于输出时的注释
+var aliases = map[string]struct {
+	typ  byte
+	name uint32
+}{
+	"to":      {'U', winbox.SysTo},
+	"from":    {'U', winbox.From},
+	"seq":     {'u', winbox.Seq},
+	"reply":   {'b', winbox.ReplyExpected},
+	"id":      {'u', winbox.RequestId},
+	"cmd":     {'u', winbox.Command},
+	"session": {'u', winbox.SessionId},
+}
+
+var fieldNames = map[uint32]string{
+	winbox.SysTo:         "sys_to",
+	winbox.From:          "from",
+	winbox.Seq:           "seq",
+	winbox.ReplyExpected: "reply_expected",
+	winbox.RequestId:     "request_id",
+	winbox.Command:       "command",
+	winbox.ErrorCode:     "error_code",
+	winbox.ErrorString:   "error_string",
+	winbox.SessionId:     "session_id",
+}
+
+// splitWords 按空白切分, 支持单双引号
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// buildMessage 根据 key=value 形式的字段构造消息
+// key 为别名(to、cmd 等)或 类型字符+十六进制字段名(u2、s1、Uff0001 等)
+func buildMessage(args []string) (*winbox.Message, error) {
+	msg := winbox.NewMessage()
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("bad field %q, expected key=value", arg)
+		}
+
+		typ, name := byte(0), uint32(0)
+		if a, ok := aliases[key]; ok {
+			typ, name = a.typ, a.name
+		} else {
+			if len(key) < 2 {
+				return nil, fmt.Errorf("bad field name %q", key)
+			}
+			n, err := strconv.ParseUint(key[1:], 16, 24)
+			if err != nil {
+				return nil, fmt.Errorf("bad field name %q", key)
+			}
+			typ, name = key[0], uint32(n)
+		}
+
+		if err := setField(msg, typ, name, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return msg, nil
+}
+
+func setField(msg *winbox.Message, typ byte, name uint32, value string) error {
+	switch typ {
+	case 'b':
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		msg.AddBoolean(name, v)
+	case 'u':
+		v, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return err
+		}
+		msg.AddU32(name, uint32(v))
+	case 'q':
+		v, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return err
+		}
+		msg.AddU64(name, v)
+	case 's':
+		msg.AddString(name, value)
+	case 'r':
+		v, err := hex.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("raw values are given in hex: %w", err)
+		}
+		msg.AddRaw(name, string(v))
+	case 'U':
+		var values []uint32
+		for _, s := range strings.Split(value, ",") {
+			if s == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(s, 0, 32)
+			if err != nil {
+				return err
+			}
+			values = append(values, uint32(v))
+		}
+		msg.AddU32Array(name, values)
+	case 'S':
+		msg.AddStringArray(name, strings.Split(value, ","))
+	default:
+		return fmt.Errorf("type %q is not supported here, use the {...} notation", typ)
+	}
+	return nil
+}
+
+// topLevelFields 把文本格式的消息拆分为顶层字段
+func topLevelFields(text string) []string {
+	if len(text) < 2 {
+		return nil
+	}
+	body := text[1 : len(text)-1]
+
+	var fields []string
+	depth, start := 0, 0
+	quoted := false
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if quoted {
+			// 与解析器一致: 跳过转义的字符, 后面紧跟 , ] } 或结尾的单引号结束字符串
+			if c == '\\' && i+1 < len(body) {
+				i++
+				continue
+			}
+			if c == '\'' && (i+1 == len(body) || strings.IndexByte(",]}", body[i+1]) >= 0) {
+				quoted = false
+			}
+			continue
+		}
+		switch c {
+		case '\'':
+			quoted = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(body) {
+		fields = append(fields, body[start:])
+	}
+	return fields
+}
+
+// pretty 每行输出一个顶层字段, 对常用字段和原始数据加注释
+func pretty(msg *winbox.Message) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, field := range topLevelFields(msg.SerializeToJson()) {
+		key, _, _ := strings.Cut(field, ":")
+		fmt.Fprintf(&b, "  %s", field)
+
+		var note string
+		if n, err := strconv.ParseUint(key[1:], 16, 24); err == nil {
+			if name, ok := fieldNames[uint32(n)]; ok {
+				note = name
+			}
+			if key[0] == 'r' {
+				note = describeRaw(msg.GetRaw(uint32(n)))
+			}
+		}
+		if note != "" {
+			fmt.Fprintf(&b, "    # %s", note)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	if msg.HasError() {
+		fmt.Fprintf(&b, "\nerror: %s", msg.ErrorString())
+	}
+	return b.String()
+}
+
+func describeRaw(raw string) string {
+	printable := true
+	for _, r := range raw {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		if len(raw) > 60 {
+			return strconv.Quote(raw[:60]) + "..."
+		}
+		return strconv.Quote(raw)
+	}
+	if len(raw) > 32 {
+		return hex.EncodeToString([]byte(raw[:32])) + "..."
+	}
+	return hex.EncodeToString([]byte(raw))
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"router/pkg/client"
+	"router/pkg/winbox"
+)
+
+const usage = `commands:
+  connect host:port        connect (closes the current connection)
+  close                    close the current connection
+  {u2:1,Uff0001:[13,4]}    send a message in text notation exactly as typed and wait for a reply
+  send key=value ...       send named fields, seq/request id/reply expected are filled in
+                           keys: to from seq id cmd session reply, or type+hex name (u2 s1 r3 Uff0001)
+  recv                     wait for the next message from the server
+  login [user [password]]  MD5 challenge login (default admin with an empty password)
+  list                     download and print the plugin list
+  get path [file]          download a file through mproxy (saved under its base name by default)
+  timeout duration         set the read timeout, e.g. 3s
+  history                  show the command history (login passwords saved as ***), !N repeats entry N, !! the last one
+  help                     show this text
+  quit                     exit`
+
+type shell struct {
+	addr    string
+	timeout time.Duration
+	client  *client.Client
+	history []string
+	histOut *os.File
+}
+
+func main() {
+	timeout := flag.Duration("t", 5*time.Second, "read timeout for replies")
+	histPath := flag.String("history", defaultHistory(), "history file, empty to disable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-t timeout] [-history file] [host:port]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sh := &shell{timeout: *timeout}
+	if *histPath != "" {
+		sh.loadHistory(*histPath)
+		defer sh.histOut.Close()
+	}
+	if flag.NArg() == 1 {
+		if err := sh.connect(flag.Arg(0)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	sh.run(os.Stdin)
+	if sh.client != nil {
+		sh.client.Close()
+	}
+}
+
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".winboxsh_history")
+}
+
+// loadHistory 读取历史文件, 并打开它用于追加新的命令
+func (s *shell) loadHistory(path string) {
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				s.history = append(s.history, redact(line))
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+		return
+	}
+	s.histOut = f
+}
+
+// remember 把命令加入历史, 登录口令不会出现在内存和历史文件中
+func (s *shell) remember(line string) {
+	line = redact(line)
+	if n := len(s.history); n > 0 && s.history[n-1] == line {
+		return
+	}
+	s.history = append(s.history, line)
+	if s.histOut != nil {
+		fmt.Fprintln(s.histOut, line)
+	}
+}
+
+// redact 把 login user password 中的口令替换为 ***
+func redact(line string) string {
+	words, err := splitWords(line)
+	if err != nil || len(words) < 3 || words[0] != "login" {
+		return line
+	}
+	user := words[1]
+	if strings.ContainsRune(user, '\'') {
+		user = `"` + user + `"`
+	} else if strings.ContainsAny(user, " \t\"") {
+		user = "'" + user + "'"
+	}
+	return "login " + user + " ***"
+}
+
+func (s *shell) prompt() {
+	if s.addr != "" {
+		fmt.Printf("%s> ", s.addr)
+	} else {
+		fmt.Print("winbox> ")
+	}
+}
+
+func (s *shell) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.prompt(); scanner.Scan(); s.prompt() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// !N 和 !! 重复历史中的命令
+		if strings.HasPrefix(line, "!") {
+			expanded, err := s.expand(line)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println(expanded)
+			line = expanded
+		}
+		s.remember(line)
+
+		quit, err := s.execute(line)
+		if err != nil {
+			fmt.Println("error:", err)
+		}
+		if quit {
+			return
+		}
+	}
+	fmt.Println()
+}
+
+func (s *shell) expand(line string) (string, error) {
+	if len(s.history) == 0 {
+		return "", errors.New("history is empty")
+	}
+	if line == "!!" {
+		return s.history[len(s.history)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > len(s.history) {
+		return "", fmt.Errorf("no history entry %s", line[1:])
+	}
+	return s.history[n-1], nil
+}
+
+// execute 执行一条命令, 返回 true 表示退出
+func (s *shell) execute(line string) (bool, error) {
+	if strings.HasPrefix(line, "{") {
+		msg, err := winbox.ParseText(line)
+		if err != nil {
+			return false, err
+		}
+		return false, s.sendRaw(msg)
+	}
+
+	words, err := splitWords(line)
+	if err != nil {
+		return false, err
+	}
+	cmd, args := words[0], words[1:]
+	switch cmd {
+	case "quit", "exit":
+		return true, nil
+	case "help", "?":
+		fmt.Println(usage)
+	case "history":
+		for i, h := range s.history {
+			fmt.Printf("%4d  %s\n", i+1, h)
+		}
+	case "timeout":
+		if len(args) != 1 {
+			fmt.Println(s.timeout)
+			return false, nil
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return false, err
+		}
+		s.timeout = d
+		if s.client != nil {
+			s.client.Timeout = d
+		}
+	case "connect", "open":
+		if len(args) != 1 {
+			return false, errors.New("usage: connect host:port")
+		}
+		return false, s.connect(args[0])
+	case "close":
+		s.disconnect()
+	case "send":
+		return false, s.send(args)
+	case "recv":
+		return false, s.recv()
+	case "login":
+		return false, s.login(args)
+	case "list":
+		return false, s.list()
+	case "get":
+		return false, s.get(args)
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	return false, nil
+}
+
+func (s *shell) connect(addr string) error {
+	s.disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	c, err := client.Dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	c.Timeout = s.timeout
+	s.client, s.addr = c, addr
+	fmt.Println("connected to", addr)
+	return nil
+}
+
+func (s *shell) disconnect() {
+	if s.client != nil {
+		s.client.Close()
+		fmt.Println("closed", s.addr)
+	}
+	s.client, s.addr = nil, ""
+}
+
+func (s *shell) connected() error {
+	if s.client == nil {
+		return errors.New("not connected, use connect host:port")
+	}
+	return nil
+}
+
+// check 在连接出错时关闭连接, 服务端的错误回复不影响连接
+func (s *shell) check(err error) error {
+	var replyErr *client.Error
+	if err == nil || errors.As(err, &replyErr) {
+		return err
+	}
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("no reply within %s", s.timeout)
+	}
+	s.disconnect()
+	return err
+}
+
+func (s *shell) sendRaw(msg *winbox.Message) error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	if err := s.client.Send(msg); err != nil {
+		return s.check(err)
+	}
+	return s.recv()
+}
+
+func (s *shell) send(args []string) error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	msg, err := buildMessage(args)
+	if err != nil {
+		return err
+	}
+	fmt.Println("->", msg.SerializeToJson())
+	reply, err := s.client.Request(msg)
+	if reply != nil {
+		fmt.Println(pretty(reply))
+	}
+	return s.check(err)
+}
+
+func (s *shell) recv() error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	reply, err := s.client.Receive()
+	if err != nil {
+		return s.check(err)
+	}
+	fmt.Println(pretty(reply))
+	return nil
+}
+
+func (s *shell) login(args []string) error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	user, password := "admin", ""
+	if len(args) > 0 {
+		user = args[0]
+	}
+	if len(args) > 1 {
+		password = args[1]
+	}
+	reply, err := s.client.Login(user, password)
+	if reply != nil {
+		fmt.Println(pretty(reply))
+	}
+	if err == nil {
+		fmt.Printf("logged in as %s\n", user)
+	}
+	return s.check(err)
+}
+
+func (s *shell) list() error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	data, err := s.client.ReadFile("list")
+	if err != nil {
+		return s.check(err)
+	}
+	manifest, err := winbox.ParseManifest(data)
+	if err != nil {
+		// 无法解析时原样输出
+		os.Stdout.Write(data)
+		return err
+	}
+	for _, e := range manifest {
+		fmt.Printf("%-28s %-10s %8d  crc=%d\n", e.Name, e.Version, e.Size, e.CRC)
+	}
+	fmt.Printf("%d entries\n", len(manifest))
+	return nil
+}
+
+func (s *shell) get(args []string) error {
+	if err := s.connected(); err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: get path [file]")
+	}
+	out := filepath.Base(args[0])
+	if len(args) == 2 {
+		out = args[1]
+	}
+	data, err := s.client.ReadFile(args[0])
+	if err != nil {
+		return s.check(err)
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("saved %d bytes to %s\n", len(data), out)
+	return nil
+}
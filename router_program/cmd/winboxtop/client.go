@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"router/internal/app"
+)
+
+// errNotFound 表示会话已经不在服务端
+var errNotFound = errors.New("session not found")
+
+// apiClient 访问服务端的管理接口
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// newAPIClient 创建访问 addr 的客户端, addr 为 unix:/path、host:port 或 http(s):// URL
+func newAPIClient(addr, token string) *apiClient {
+	c := &apiClient{token: token, http: &http.Client{Timeout: 5 * time.Second}}
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(addr, "unix:")
+		c.base = "http://admin"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+	case strings.HasPrefix(addr, "http://"), strings.HasPrefix(addr, "https://"):
+		c.base = strings.TrimSuffix(addr, "/")
+	default:
+		c.base = "http://" + addr
+	}
+	return c
+}
+
+func (c *apiClient) get(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("%s: %s %s", path, resp.Status, body.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sessions 返回当前会话
+func (c *apiClient) sessions() ([]app.SessionInfo, error) {
+	var list []app.SessionInfo
+	return list, c.get("/api/sessions", &list)
+}
+
+// session 返回会话的状态和最近的消息
+func (c *apiClient) session(id uint64) (app.SessionInfo, []app.Message, error) {
+	var body struct {
+		app.SessionInfo
+		Messages []app.Message `json:"messages"`
+	}
+	err := c.get(fmt.Sprintf("/api/sessions/%d", id), &body)
+	return body.SessionInfo, body.Messages, err
+}
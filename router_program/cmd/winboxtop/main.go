@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"router/internal/app"
+)
+
+const help = `keys:
+  up/down, k/j     select a session
+  enter            show the selected session's messages
+  esc, backspace   back to the session list
+  q, ctrl-c        quit`
+
+// states 把管理接口中的状态显示为服务端的状态名
+var states = map[string]string{
+	"idle":      "k_none",
+	"login":     "k_init_login",
+	"logged_in": "k_logged_in",
+	"file_open": "file open",
+	"closing":   "k_close",
+	"closed":    "closed",
+}
+
+type key int
+
+const (
+	keyUp key = iota
+	keyDown
+	keyEnter
+	keyBack
+	keyQuit
+)
+
+type top struct {
+	api      *apiClient
+	addr     string
+	out      *bufio.Writer
+	width    int
+	height   int
+	sessions []app.SessionInfo
+	selected uint64 // 选中的会话编号
+	detail   uint64 // 正在查看的会话, 0 表示会话列表
+	info     app.SessionInfo
+	messages []app.Message
+	gone     bool // 正在查看的会话已经不在服务端
+	err      error
+	updated  time.Time
+}
+
+func main() {
+	addr := flag.String("admin", "", "admin API address: unix:/path, host:port or an http(s) URL")
+	tokenFile := flag.String("token-file", "", "file with the admin token (default $WINBOX_ADMIN_TOKEN)")
+	interval := flag.Duration("i", 2*time.Second, "refresh interval")
+	once := flag.Bool("once", false, "print the session table once without terminal control and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -admin addr [-token-file file] [-i interval] [-once]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), help)
+	}
+	flag.Parse()
+	if *addr == "" || flag.NArg() != 0 || *interval <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	token := os.Getenv("WINBOX_ADMIN_TOKEN")
+	if *tokenFile != "" {
+		content, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	t := &top{api: newAPIClient(*addr, token), addr: *addr, out: bufio.NewWriter(os.Stdout), width: 120, height: 40}
+	if *once {
+		// 不截断, 便于在脚本中使用
+		t.width = 1 << 20
+		t.refresh()
+		if t.err != nil {
+			fmt.Fprintln(os.Stderr, t.err)
+			os.Exit(1)
+		}
+		t.drawList(false)
+		t.out.Flush()
+		return
+	}
+	if err := t.run(*interval); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run 在备用屏幕中定时刷新, 直到按下 q 或收到信号
+func (t *top) run(interval time.Duration) error {
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		restore = func() {}
+	}
+	defer restore()
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		t.out.WriteString("\x1b[?25h\x1b[?1049l")
+		t.out.Flush()
+	}()
+
+	keys := make(chan key)
+	go readKeys(os.Stdin, keys)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	resize := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resize, resizeSignals...)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.refresh()
+	for {
+		t.draw()
+		select {
+		case <-ticker.C:
+			t.refresh()
+		case <-resize:
+		case <-sigs:
+			return nil
+		case k, ok := <-keys:
+			if !ok || k == keyQuit {
+				return nil
+			}
+			if t.handleKey(k) {
+				t.refresh()
+			}
+		}
+	}
+}
+
+// handleKey 处理按键, 切换视图时返回 true
+func (t *top) handleKey(k key) bool {
+	if t.detail != 0 {
+		if k == keyBack {
+			t.detail = 0
+			return true
+		}
+		return false
+	}
+	i := t.selectedIndex()
+	switch k {
+	case keyUp:
+		if i > 0 {
+			t.selected = t.sessions[i-1].ID
+		}
+	case keyDown:
+		if i+1 < len(t.sessions) {
+			t.selected = t.sessions[i+1].ID
+		}
+	case keyEnter:
+		if i >= 0 {
+			t.detail = t.sessions[i].ID
+			t.gone = false
+			return true
+		}
+	}
+	return false
+}
+
+// selectedIndex 返回选中的会话在列表中的位置, 选中的会话结束后选择第一个
+func (t *top) selectedIndex() int {
+	for i, s := range t.sessions {
+		if s.ID == t.selected {
+			return i
+		}
+	}
+	if len(t.sessions) == 0 {
+		return -1
+	}
+	t.selected = t.sessions[0].ID
+	return 0
+}
+
+// refresh 从管理接口读取当前视图的数据
+func (t *top) refresh() {
+	t.err = nil
+	if t.detail == 0 {
+		t.sessions, t.err = t.api.sessions()
+	} else {
+		info, messages, err := t.api.session(t.detail)
+		switch {
+		case errors.Is(err, errNotFound):
+			t.gone = true
+		case err != nil:
+			t.err = err
+		default:
+			t.info, t.messages = info, messages
+		}
+	}
+	t.updated = time.Now()
+}
+
+func (t *top) draw() {
+	if w, h, ok := termSize(os.Stdout); ok {
+		t.width, t.height = w, h
+	}
+	t.out.WriteString("\x1b[H\x1b[2J")
+	if t.detail == 0 {
+		t.drawList(true)
+	} else {
+		t.drawDetail()
+	}
+	t.out.Flush()
+}
+
+func (t *top) drawList(ansi bool) {
+	t.line(fmt.Sprintf("winboxtop %s  %s  %d sessions  (enter: open, q: quit)", t.addr, t.updated.Format("15:04:05"), len(t.sessions)), ansi)
+	if t.err != nil {
+		t.line("error: "+t.err.Error(), false)
+	}
+	t.line("", false)
+	t.line(fmt.Sprintf("  %-6s %-22s %-12s %-13s %8s %9s %9s %5s  %s", "ID", "CLIENT", "USER", "STATE", "AGE", "IN", "OUT", "REQ", "LAST MESSAGE"), ansi)
+
+	rows := len(t.sessions)
+	if ansi && rows > t.height-5 {
+		rows = max(t.height-5, 0)
+	}
+	selected := t.selectedIndex()
+	for i, s := range t.sessions[:rows] {
+		last := ""
+		if s.Last != nil {
+			last = arrow(s.Last.Direction) + " " + shorten(s.Last.Text, 120)
+		}
+		marker := " "
+		if ansi && i == selected {
+			marker = ">"
+		}
+		row := fmt.Sprintf("%s %-6d %-22s %-12s %-13s %8s %9s %9s %5d  %s", marker, s.ID,
+			fmt.Sprintf("%s:%d", s.Client, s.Port), s.User, state(s.State),
+			time.Since(s.Started).Round(time.Second), formatBytes(s.BytesIn), formatBytes(s.BytesOut), s.Requests, last)
+		t.line(row, ansi && i == selected)
+	}
+	if rows < len(t.sessions) {
+		t.line(fmt.Sprintf("  ... %d more", len(t.sessions)-rows), false)
+	}
+}
+
+func (t *top) drawDetail() {
+	s := t.info
+	status := state(s.State)
+	if t.gone {
+		status = "closed (no longer on the server)"
+	}
+	t.line(fmt.Sprintf("session %d  %s:%d  %s  (esc: back, q: quit)", t.detail, s.Client, s.Port, t.updated.Format("15:04:05")), true)
+	if t.err != nil {
+		t.line("error: "+t.err.Error(), false)
+	}
+	t.line(fmt.Sprintf("listener %s  persona %s  user %s  state %s", s.Listener, s.Persona, s.User, status), false)
+	t.line(fmt.Sprintf("started %s  in %s  out %s  requests %d  replies %d", s.Started.Local().Format("15:04:05"),
+		formatBytes(s.BytesIn), formatBytes(s.BytesOut), s.Requests, s.Replies), false)
+	t.line("", false)
+
+	// 只显示能放下的最近消息, 最新的在最下面
+	messages := t.messages
+	if n := t.height - 5; len(messages) > n {
+		messages = messages[len(messages)-max(n, 0):]
+	}
+	for _, m := range messages {
+		t.line(fmt.Sprintf("%s %s %s", m.Time.Local().Format("15:04:05.000"), arrow(m.Direction), m.Text), false)
+	}
+}
+
+// line 输出一行, 超过终端宽度的部分被截断, reverse 为 true 时反色显示
+func (t *top) line(s string, reverse bool) {
+	if utf8.RuneCountInString(s) > t.width {
+		s = string([]rune(s)[:t.width])
+	}
+	if reverse {
+		s = "\x1b[7m" + s + strings.Repeat(" ", t.width-utf8.RuneCountInString(s)) + "\x1b[0m"
+	}
+	t.out.WriteString(s + "\n")
+}
+
+// readKeys 从终端读取按键, 输入结束时关闭 keys
+func readKeys(r io.Reader, keys chan<- key) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// parseKeys 解析一次读到的输入, 方向键为 ESC [ A 或 ESC O A
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) >= 3 && (b[1] == '[' || b[1] == 'O') {
+				switch b[2] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				case 'C':
+					keys = append(keys, keyEnter)
+				case 'D':
+					keys = append(keys, keyBack)
+				}
+				b = b[3:]
+				continue
+			}
+			keys = append(keys, keyBack)
+			b = b[1:]
+			continue
+		}
+		switch b[0] {
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case 0x7f, 0x08, 'h':
+			keys = append(keys, keyBack)
+		case 'q', 'Q':
+			keys = append(keys, keyQuit)
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// shorten 把 s 截断到 n 个字符
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+func state(s string) string {
+	if name, ok := states[s]; ok {
+		return name
+	}
+	return s
+}
+
+func arrow(direction string) string {
+	if direction == "in" {
+		return "<-"
+	}
+	return "->"
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
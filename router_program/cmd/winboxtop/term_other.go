@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var resizeSignals []os.Signal
+
+// makeRaw 在这些平台上不支持, 按键需要回车后生效
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func termSize(f *os.File) (int, int, bool) {
+	return 0, 0, false
+}
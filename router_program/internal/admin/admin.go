@@ -0,0 +1,208 @@
+// Package admin 提供本机管理接口和网页: 查看和关闭会话, 重新加载配置, 打开单个会话的调试日志, 查看攻击统计
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"router/internal/app"
+	"router/internal/config"
+	"router/internal/events"
+)
+
+// Handler 是管理接口, 令牌取自当前配置的 admin, 重新加载后立即生效
+// 令牌为空时不检查认证, 只应用于权限受限的 unix socket
+// 浏览器在 /login 输入令牌后, cookie 中保存随机的会话编号, 修改操作还必须带有 csrfHeader
+type Handler struct {
+	server *app.Server
+	conf   *config.Manager
+	store  *events.Store
+	logger *slog.Logger
+	mux    *http.ServeMux
+
+	mu          sync.Mutex
+	webSessions map[string]webSession
+}
+
+// New 创建管理接口, store 为 nil 时网页中没有统计和事件
+func New(server *app.Server, conf *config.Manager, store *events.Store, logger *slog.Logger) *Handler {
+	h := &Handler{server: server, conf: conf, store: store, logger: logger, mux: http.NewServeMux(), webSessions: make(map[string]webSession)}
+	h.mux.HandleFunc("GET /{$}", h.dashboard)
+	h.mux.HandleFunc("GET /sessions/{id}", h.sessionPage)
+	h.mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	h.mux.HandleFunc("GET /api/stats", h.stats)
+	h.mux.HandleFunc("GET /api/events", h.recentEvents)
+	h.mux.HandleFunc("GET /api/sessions", h.listSessions)
+	h.mux.HandleFunc("GET /api/sessions/{id}", h.getSession)
+	h.mux.HandleFunc("DELETE /api/sessions/{id}", h.killSession)
+	h.mux.HandleFunc("POST /api/sessions/{id}/debug", h.debugSession)
+	h.mux.HandleFunc("POST /api/reload", h.reload)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/login" {
+		switch r.Method {
+		case http.MethodGet:
+			h.loginPage(w, r)
+		case http.MethodPost:
+			h.login(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	ok, cookie := h.authorize(r)
+	if !ok {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+	// 跨站的表单可以带上 cookie, 但不能设置自定义头部
+	if cookie && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get(csrfHeader) == "" {
+		writeError(w, http.StatusForbidden, "requests authenticated by cookie must set the "+csrfHeader+" header")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorize 检查 Bearer 令牌或登录后的 cookie, cookie 为 true 表示通过 cookie 认证
+func (h *Handler) authorize(r *http.Request) (ok, cookie bool) {
+	secret := h.conf.Current().Admin.Secret
+	if secret == "" {
+		return true, false
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1, false
+	}
+	c, err := r.Cookie(cookieName)
+	if err != nil {
+		return false, false
+	}
+	return h.validWebSession(c.Value, secret), true
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusNotFound, "no event store")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.store.Stats(topN))
+}
+
+func (h *Handler) recentEvents(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusNotFound, "no event store")
+		return
+	}
+	n := recentEvents
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "n must be a positive number")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, h.store.Recent(n))
+}
+
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.server.Sessions())
+}
+
+func (h *Handler) getSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+	info, messages, ok := h.server.Session(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such session")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		app.SessionInfo
+		Messages []app.Message `json:"messages"`
+	}{info, messages})
+}
+
+func (h *Handler) killSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+	if !h.server.KillSession(id) {
+		writeError(w, http.StatusNotFound, "no such session")
+		return
+	}
+	h.logger.Info("管理接口关闭会话", "conn", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) debugSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body); err != nil || body.Enabled == nil {
+		writeError(w, http.StatusBadRequest, `body must be {"enabled": true|false}`)
+		return
+	}
+	if !h.server.SetSessionDebug(id, *body.Enabled) {
+		writeError(w, http.StatusNotFound, "no such session")
+		return
+	}
+	h.logger.Info("管理接口设置会话调试日志", "conn", id, "enabled", *body.Enabled)
+	writeJSON(w, http.StatusOK, map[string]bool{"debug": *body.Enabled})
+}
+
+func (h *Handler) reload(w http.ResponseWriter, r *http.Request) {
+	if h.conf.Path() == "" {
+		writeError(w, http.StatusConflict, "no config file was given with -c")
+		return
+	}
+	old := h.conf.Current()
+	if err := h.server.Reload(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	changes := config.Diff(old, h.conf.Current())
+	if changes == nil {
+		changes = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"changes": changes})
+}
+
+func sessionID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "session id must be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
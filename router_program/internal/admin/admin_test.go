@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"router/internal/app"
+	"router/internal/config"
+	"router/internal/events"
+)
+
+const testConfig = `{"version": 1, "user": "admin", "password": "admin", "admin": {"token": "%s"}}`
+
+func newHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, "s3cret")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m, err := config.NewManager(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := app.NewServer(app.WithConfigManager(m), app.WithLogger(logger))
+	return New(server, m, events.NewStore(100), logger), path
+}
+
+func writeConfig(t *testing.T, path, token string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Replace(testConfig, "%s", token, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// do 发送请求, header 为附加的头部, 例如 Authorization 或 Cookie
+func do(h http.Handler, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func login(t *testing.T, h http.Handler, token string) *http.Cookie {
+	t.Helper()
+	w := do(h, http.MethodPost, "/login", strings.NewReader(url.Values{"token": {token}}.Encode()),
+		"Content-Type", "application/x-www-form-urlencoded")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Fatalf("login returned %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookieName {
+			return c
+		}
+	}
+	t.Fatal("login did not set the cookie")
+	return nil
+}
+
+func TestLoginCookieIsNotTheToken(t *testing.T) {
+	h, _ := newHandler(t)
+	c := login(t, h, "s3cret")
+	if strings.Contains(c.Value, "s3cret") || len(c.Value) != 64 || !c.HttpOnly {
+		t.Errorf("cookie %+v should be a random HttpOnly session id", c)
+	}
+	if w := do(h, http.MethodGet, "/api/sessions", nil, "Cookie", c.String()); w.Code != http.StatusOK {
+		t.Errorf("GET with the session cookie returned %d", w.Code)
+	}
+	raw := &http.Cookie{Name: cookieName, Value: "s3cret"}
+	if w := do(h, http.MethodGet, "/api/sessions", nil, "Cookie", raw.String()); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET with the token as cookie returned %d, want 401", w.Code)
+	}
+}
+
+func TestLoginBadToken(t *testing.T) {
+	h, _ := newHandler(t)
+	w := do(h, http.MethodPost, "/login", strings.NewReader("token=wrong"), "Content-Type", "application/x-www-form-urlencoded")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?failed" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("login with a bad token returned %d to %q with cookies %v", w.Code, w.Header().Get("Location"), w.Result().Cookies())
+	}
+}
+
+func TestCookieNeedsCSRFHeader(t *testing.T) {
+	h, _ := newHandler(t)
+	c := login(t, h, "s3cret")
+
+	for _, req := range []struct{ method, target string }{
+		{http.MethodPost, "/api/reload"},
+		{http.MethodDelete, "/api/sessions/1"},
+		{http.MethodPost, "/api/sessions/1/debug"},
+	} {
+		if w := do(h, req.method, req.target, strings.NewReader(`{"enabled":true}`), "Cookie", c.String()); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s with a cookie and no %s returned %d, want 403", req.method, req.target, csrfHeader, w.Code)
+		}
+	}
+	if w := do(h, http.MethodPost, "/api/reload", nil, "Cookie", c.String(), csrfHeader, "XMLHttpRequest"); w.Code != http.StatusOK {
+		t.Errorf("POST /api/reload with a cookie and %s returned %d: %s", csrfHeader, w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/api/reload", nil, "Authorization", "Bearer s3cret"); w.Code != http.StatusOK {
+		t.Errorf("POST /api/reload with the bearer token returned %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestTokenChangeEndsWebSessions(t *testing.T) {
+	h, path := newHandler(t)
+	c := login(t, h, "s3cret")
+
+	writeConfig(t, path, "n3w-secret")
+	if w := do(h, http.MethodPost, "/api/reload", nil, "Authorization", "Bearer s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("reload returned %d: %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/api/sessions", nil, "Cookie", c.String()); w.Code != http.StatusUnauthorized {
+		t.Errorf("cookie from before the token change returned %d, want 401", w.Code)
+	}
+}
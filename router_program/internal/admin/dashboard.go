@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"router/internal/events"
+)
+
+//go:embed web
+var web embed.FS
+
+// static 是网页使用的样式表
+var static, _ = fs.Sub(web, "web/static")
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"clock":    func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+	"since":    func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+	"bytes":    formatBytes,
+	"describe": describe,
+	"dict":     dict,
+}).ParseFS(web, "web/*.html"))
+
+// cookieName 是浏览器保存会话编号的 cookie
+const cookieName = "winbox_admin"
+
+// webSessionTTL 是浏览器登录的有效期
+const webSessionTTL = 12 * time.Hour
+
+// csrfHeader 是通过 cookie 认证的修改操作(POST、DELETE)必须带有的头部, 取值不限
+const csrfHeader = "X-Requested-With"
+
+// webSession 是浏览器登录后的会话
+type webSession struct {
+	secret  string // 登录时的令牌, 令牌修改后会话失效
+	expires time.Time
+}
+
+// 管理界面中每项统计显示的数量
+const (
+	topN         = 10
+	recentEvents = 50
+)
+
+// timelineEntry 是会话时间线中的一条消息或事件
+type timelineEntry struct {
+	Time time.Time
+	Kind string // in、out 或事件类型
+	Text string
+}
+
+func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) {
+	var stats events.Stats
+	var recent []events.Event
+	if h.store != nil {
+		stats = h.store.Stats(topN)
+		recent = h.store.Recent(recentEvents)
+	}
+	render(w, "index.html", map[string]any{
+		"Sessions": h.server.Sessions(),
+		"Finished": h.server.Finished(),
+		"Stats":    stats,
+		"Events":   recent,
+		"Types":    events.Types,
+	})
+}
+
+func (h *Handler) sessionPage(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionID(w, r)
+	if !ok {
+		return
+	}
+	info, messages, ok := h.server.Session(id)
+	var evs []events.Event
+	if h.store != nil {
+		evs = h.store.ForConn(id)
+	}
+	if !ok && len(evs) == 0 {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+
+	var timeline []timelineEntry
+	for _, m := range messages {
+		timeline = append(timeline, timelineEntry{Time: m.Time, Kind: m.Direction, Text: m.Text})
+	}
+	for _, e := range evs {
+		timeline = append(timeline, timelineEntry{Time: e.Time, Kind: string(e.Type), Text: describe(e)})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+	render(w, "session.html", map[string]any{
+		"ID":       id,
+		"Found":    ok,
+		"Info":     info,
+		"Timeline": timeline,
+	})
+}
+
+func (h *Handler) loginPage(w http.ResponseWriter, r *http.Request) {
+	render(w, "login.html", map[string]any{"Failed": r.URL.Query().Has("failed")})
+}
+
+// login 校验表单中的令牌, 正确时创建会话, cookie 中只保存随机的会话编号
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	secret := h.conf.Current().Admin.Secret
+	token := r.PostFormValue("token")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		h.logger.Warn("管理界面登录失败", "remote", r.RemoteAddr)
+		http.Redirect(w, r, "/login?failed", http.StatusSeeOther)
+		return
+	}
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	value := hex.EncodeToString(id)
+
+	now := time.Now()
+	h.mu.Lock()
+	for k, s := range h.webSessions {
+		if now.After(s.expires) {
+			delete(h.webSessions, k)
+		}
+	}
+	h.webSessions[value] = webSession{secret: secret, expires: now.Add(webSessionTTL)}
+	h.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: cookieName, Value: value, Path: "/", MaxAge: int(webSessionTTL / time.Second),
+		HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// validWebSession 判断 cookie 中的会话是否有效, 过期或令牌已修改的会话被删除
+func (h *Handler) validWebSession(id, secret string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.webSessions[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(s.expires) || subtle.ConstantTimeCompare([]byte(s.secret), []byte(secret)) != 1 {
+		delete(h.webSessions, id)
+		return false
+	}
+	return true
+}
+
+func render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// describe 返回事件中除会话信息以外的内容
+func describe(e events.Event) string {
+	var parts []string
+	if e.User != "" && (e.Type == events.LoginAttempt || e.Type == events.LoginFailure || e.Type == events.LoginSuccess) {
+		parts = append(parts, "user="+e.User)
+	}
+	if e.Path != "" {
+		parts = append(parts, "path="+e.Path)
+	}
+	if e.Size != 0 {
+		parts = append(parts, fmt.Sprintf("size=%d", e.Size))
+	}
+	if e.Reason != "" {
+		parts = append(parts, "reason="+e.Reason)
+	}
+	if e.Type == events.ConnectionClose {
+		parts = append(parts, fmt.Sprintf("duration=%s in=%s out=%s requests=%d",
+			(time.Duration(e.DurationMs)*time.Millisecond).String(), formatBytes(e.BytesIn), formatBytes(e.BytesOut), e.Requests))
+	}
+	return strings.Join(parts, " ")
+}
+
+// dict 把键值对组成 map, 用于向子模板传递多个参数
+func dict(pairs ...any) map[string]any {
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m[pairs[i].(string)] = pairs[i+1]
+	}
+	return m
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
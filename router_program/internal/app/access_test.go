@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"router/internal/proxyproto"
+)
+
+// 访问控制按 PROXY 头部中的客户端地址判断, 而不是负载均衡(127.0.0.1)的地址
+func TestAccessWithProxyProtocol(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		access   string
+		client   string
+		rejected bool
+		filtered bool
+	}{
+		{name: "no rules", access: `{}`, client: "203.0.113.7"},
+		{name: "denied client", access: `{"deny": ["203.0.113.0/24"]}`, client: "203.0.113.7", rejected: true},
+		{name: "denied proxy", access: `{"deny": ["127.0.0.1"]}`, client: "198.51.100.7"},
+		{name: "allowed client", access: `{"allow": ["198.51.100.0/24"]}`, client: "198.51.100.7"},
+		{name: "not allowed client", access: `{"allow": ["198.51.100.0/24"]}`, client: "203.0.113.7", rejected: true},
+		{name: "allowed proxy only", access: `{"allow": ["127.0.0.1"]}`, client: "203.0.113.7", rejected: true},
+		{name: "reset", access: `{"deny": ["203.0.113.7"], "action": "reset"}`, client: "203.0.113.7", rejected: true},
+		{name: "persona", access: `{"deny": ["203.0.113.7"], "action": "persona", "persona": "decoy"}`, client: "203.0.113.7", filtered: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testConfig(t, `{"user": "admin", "password": "admin", "trustedProxies": ["127.0.0.1"],
+				"personas": {"decoy": {"board": "CCR1009"}}, "access": `+tt.access+`}`)
+			l := proxyproto.NewListener(listen(t), func(addr netip.Addr) bool {
+				return m.Current().TrustedProxy(addr)
+			})
+			s := NewServer(WithConfigManager(m), WithListener(l), WithLogger(discardLogger))
+			serve(t, s)
+
+			conn := dialServer(t, l.Addr().String())
+			fmt.Fprintf(conn, "PROXY TCP4 %s 127.0.0.1 40000 8291\r\n", tt.client)
+
+			if tt.rejected {
+				expectClosed(t, conn)
+				waitFor(t, "the access rejection", func() bool { return s.Rejected()[RejectAccess] == 1 })
+				return
+			}
+			login(t, conn)
+			sessions := s.Sessions()
+			if len(sessions) != 1 {
+				t.Fatalf("%d sessions, want 1", len(sessions))
+			}
+			info := sessions[0]
+			if info.Client != tt.client {
+				t.Errorf("session client is %s, want %s", info.Client, tt.client)
+			}
+			if info.Filtered != tt.filtered {
+				t.Errorf("session filtered is %v, want %v", info.Filtered, tt.filtered)
+			}
+			if tt.filtered && info.Persona != "decoy" {
+				t.Errorf("filtered session uses persona %q", info.Persona)
+			}
+			if n := s.Rejected()[RejectAccess]; n != 0 {
+				t.Errorf("%d connections rejected by access", n)
+			}
+		})
+	}
+}
+
+// 没有 PROXY 头部时按连接的来源地址判断
+func TestAccessDirect(t *testing.T) {
+	s, addr := startServer(t, `{"user": "admin", "password": "admin", "access": {"deny": ["127.0.0.0/8"]}}`)
+	expectClosed(t, dialServer(t, addr))
+	waitFor(t, "the access rejection", func() bool { return s.Rejected()[RejectAccess] == 1 })
+
+	s, addr = startServer(t, `{"user": "admin", "password": "admin", "access": {"allow": ["127.0.0.1"]}}`)
+	login(t, dialServer(t, addr))
+	if n := s.Rejected()[RejectAccess]; n != 0 {
+		t.Errorf("%d connections rejected by access", n)
+	}
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"router/internal/config"
+	"router/internal/events"
+	"router/internal/proxyproto"
+)
+
+// ErrByteBudget 表示会话收发的数据超过了 limits.maxBytes
+var ErrByteBudget = errors.New("app: session byte budget exceeded")
+
+// 拒绝连接的原因
+const (
+	RejectMaxConns      = "max_conns"
+	RejectMaxConnsPerIP = "max_conns_per_ip"
+	RejectAccess        = "access"
+)
+
+// sessionConn 为会话设置读写超时, 并统计收发的字节数
+type sessionConn struct {
+	net.Conn
+	limits config.Limits
+
+	mu       sync.Mutex
+	waiting  bool // 正在等待下一个请求, 使用 IdleTimeout
+	read     int64
+	written  int64
+	exceeded bool
+}
+
+func newSessionConn(conn net.Conn, limits config.Limits) *sessionConn {
+	return &sessionConn{Conn: conn, limits: limits, waiting: true}
+}
+
+// expectRequest 在处理完一个请求后调用, 下一次读取使用空闲超时
+func (c *sessionConn) expectRequest() {
+	c.mu.Lock()
+	c.waiting = true
+	c.mu.Unlock()
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	timeout := c.limits.ReadTimeout
+	if c.waiting {
+		timeout = c.limits.IdleTimeout
+	}
+	c.mu.Unlock()
+	if timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(time.Duration(timeout)))
+	}
+
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n > 0 && c.waiting {
+		// 收到请求的第一个字节, 剩余部分使用读取超时
+		c.waiting = false
+	}
+	c.read += int64(n)
+	if c.overBudget() {
+		return n, ErrByteBudget
+	}
+	return n, err
+}
+
+func (c *sessionConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.overBudget() || c.limits.MaxBytes > 0 && c.read+c.written+int64(len(b)) > c.limits.MaxBytes {
+		c.exceeded = true
+		c.mu.Unlock()
+		return 0, ErrByteBudget
+	}
+	c.mu.Unlock()
+
+	if c.limits.WriteTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(time.Duration(c.limits.WriteTimeout)))
+	}
+	n, err := c.Conn.Write(b)
+
+	c.mu.Lock()
+	c.written += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// overBudget 调用者持有 mu
+func (c *sessionConn) overBudget() bool {
+	if c.limits.MaxBytes > 0 && c.read+c.written > c.limits.MaxBytes {
+		c.exceeded = true
+	}
+	return c.exceeded
+}
+
+// counts 返回收发的字节数以及是否超出限制
+func (c *sessionConn) counts() (read, written int64, exceeded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.read, c.written, c.exceeded
+}
+
+// remoteIP 返回连接的来源地址, IPv4 映射的 IPv6 地址按 IPv4 处理
+func remoteIP(conn net.Conn) netip.Addr {
+	ap, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
+// acquireIP 为来源地址占用一个连接名额, 超过 max 时返回 false; max 为 0 时不限制
+func (s *Server) acquireIP(ip netip.Addr, max int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if max > 0 && s.perIP[ip] >= max {
+		return false
+	}
+	s.perIP[ip]++
+	return true
+}
+
+func (s *Server) releaseIP(ip netip.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.perIP[ip]--; s.perIP[ip] <= 0 {
+		delete(s.perIP, ip)
+	}
+}
+
+// resetConn 关闭时直接发送 RST 而不是 FIN
+func resetConn(conn net.Conn) {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			c.SetLinger(0)
+			return
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return
+		}
+	}
+}
+
+// reject 关闭连接并记录拒绝的原因
+// PROXY 连接先在超时内读取头部, 记录真实的客户端地址而不是负载均衡的地址
+func (s *Server) reject(conn net.Conn, reason string) {
+	if pc, ok := conn.(*proxyproto.Conn); ok {
+		pc.Handshake()
+	}
+	conn.Close()
+	s.metrics.ConnectionsReject.Inc(reason)
+	s.mu.Lock()
+	s.rejected[reason]++
+	total := s.rejected[reason]
+	active := len(s.conns)
+	s.mu.Unlock()
+	s.logger.Warn("拒绝连接", "client", conn.RemoteAddr().String(), "reason", reason, "rejected", total, "active", active)
+
+	host, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	portNum, _ := strconv.Atoi(port)
+	s.events.Emit(events.Event{Type: events.ConnectionRejected, Client: host, Port: portNum, Listener: conn.LocalAddr().String(), Reason: reason})
+}
+
+// Rejected 返回按原因统计的被拒绝的连接数
+func (s *Server) Rejected() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]uint64, len(s.rejected))
+	for reason, n := range s.rejected {
+		counts[reason] = n
+	}
+	return counts
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"router/internal/config"
+	"router/pkg/client"
+)
+
+// login 在 conn 上登录, 返回后会话已经占用了连接名额
+func login(t *testing.T, conn net.Conn) {
+	t.Helper()
+	c := client.New(conn)
+	c.Timeout = 5 * time.Second
+	if _, err := c.Login("admin", "admin"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	s, addr := startServer(t, `{"user": "admin", "password": "admin", "limits": {"maxConns": 1, "maxConnsPerIP": 1}}`)
+
+	first := dialServer(t, addr)
+	login(t, first)
+
+	expectClosed(t, dialServer(t, addr))
+	waitFor(t, "the max_conns rejection", func() bool { return s.Rejected()[RejectMaxConns] == 1 })
+
+	// 第一个连接关闭后可以再次连接
+	first.Close()
+	waitFor(t, "the first session to end", func() bool { return s.ActiveConns() == 0 })
+	login(t, dialServer(t, addr))
+}
+
+func TestTrackMaxConns(t *testing.T) {
+	s := NewServer(WithLogger(discardLogger))
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	if reason, err := s.track(a, 1); reason != "" || err != nil {
+		t.Fatalf("first track returned %q, %v", reason, err)
+	}
+	if reason, err := s.track(b, 1); reason != RejectMaxConns || err != nil {
+		t.Fatalf("track over the limit returned %q, %v", reason, err)
+	}
+	if reason, err := s.track(b, 0); reason != "" || err != nil {
+		t.Fatalf("track without a limit returned %q, %v", reason, err)
+	}
+	s.untrack(a)
+	s.untrack(b)
+
+	s.close()
+	if _, err := s.track(a, 0); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("track after close returned %v", err)
+	}
+}
+
+func TestMaxConnsPerIP(t *testing.T) {
+	s, addr := startServer(t, `{"user": "admin", "password": "admin", "limits": {"maxConnsPerIP": 1}}`)
+
+	login(t, dialServer(t, addr))
+	expectClosed(t, dialServer(t, addr))
+	waitFor(t, "the max_conns_per_ip rejection", func() bool { return s.Rejected()[RejectMaxConnsPerIP] == 1 })
+	if n := s.Rejected()[RejectMaxConns]; n != 0 {
+		t.Errorf("%d connections rejected by max_conns", n)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	_, addr := startServer(t, `{"user": "admin", "password": "admin", "limits": {"idleTimeout": "100ms"}}`)
+
+	conn := dialServer(t, addr)
+	login(t, conn)
+	start := time.Now()
+	expectClosed(t, conn)
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("idle session closed after %v", d)
+	}
+}
+
+// pipe 返回服务端一侧包装后的 sessionConn 和客户端一侧的连接
+func pipe(t *testing.T, limits config.Limits) (*sessionConn, net.Conn) {
+	server, peer := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		peer.Close()
+	})
+	return newSessionConn(server, limits), peer
+}
+
+func TestSessionConnDeadlines(t *testing.T) {
+	const short, long = 50 * time.Millisecond, time.Hour
+	buf := make([]byte, 16)
+
+	// 等待请求时使用 idleTimeout
+	sc, _ := pipe(t, config.Limits{IdleTimeout: config.Duration(short), ReadTimeout: config.Duration(long)})
+	if _, err := sc.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("idle read returned %v, want a deadline error", err)
+	}
+
+	// 收到请求的第一个字节后使用 readTimeout
+	sc, peer := pipe(t, config.Limits{IdleTimeout: config.Duration(long), ReadTimeout: config.Duration(short)})
+	go peer.Write([]byte{1})
+	if _, err := sc.Read(buf); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	if _, err := sc.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("read inside a request returned %v, want a deadline error", err)
+	}
+
+	// 回复没有被读取时 writeTimeout 到期
+	sc, _ = pipe(t, config.Limits{WriteTimeout: config.Duration(short)})
+	if _, err := sc.Write([]byte("reply")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("blocked write returned %v, want a deadline error", err)
+	}
+}
+
+func TestSessionConnByteBudget(t *testing.T) {
+	sc, peer := pipe(t, config.Limits{MaxBytes: 10})
+	buf := make([]byte, 16)
+
+	go peer.Write(make([]byte, 6))
+	if n, err := sc.Read(buf); n != 6 || err != nil {
+		t.Fatalf("read returned %d, %v", n, err)
+	}
+
+	// 写入会超出限制时不发送任何数据
+	if n, err := sc.Write(make([]byte, 5)); n != 0 || !errors.Is(err, ErrByteBudget) {
+		t.Fatalf("write over the budget returned %d, %v", n, err)
+	}
+	read, written, exceeded := sc.counts()
+	if read != 6 || written != 0 || !exceeded {
+		t.Errorf("counts are %d, %d, %v", read, written, exceeded)
+	}
+
+	// 超出之后读取也返回 ErrByteBudget
+	go peer.Write([]byte{1})
+	if _, err := sc.Read(buf); !errors.Is(err, ErrByteBudget) {
+		t.Errorf("read after the budget was exceeded returned %v", err)
+	}
+}
+
+func TestSessionConnReadOverBudget(t *testing.T) {
+	sc, peer := pipe(t, config.Limits{MaxBytes: 4})
+	go peer.Write(make([]byte, 8))
+	n, err := sc.Read(make([]byte, 8))
+	if n != 8 || !errors.Is(err, ErrByteBudget) {
+		t.Fatalf("read over the budget returned %d, %v", n, err)
+	}
+}
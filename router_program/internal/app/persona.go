@@ -0,0 +1,27 @@
+package app
+
+import (
+	"router/internal/config"
+	"router/pkg/winbox"
+)
+
+// Persona 是登录成功后回复给客户端的设备信息
+type Persona = config.Persona
+
+// DefaultPersona 模拟 RB952Ui-5ac2nD
+var DefaultPersona = config.DefaultPersona
+
+// addPersona 把设备信息写入登录成功的回复
+func addPersona(msg *winbox.Message, p *Persona) {
+	msg.AddBoolean(0x13, false)
+	msg.AddU32(0xb, 52486)
+	msg.AddU32(0xf, 0)
+	msg.AddU32(0x10, 4)
+	msg.AddString(0x11, p.Arch)
+	msg.AddString(0x12, p.Device)
+	msg.AddString(0x14, p.License)
+	msg.AddString(0x15, p.Board)
+	msg.AddString(0x16, p.Version)
+	msg.AddString(0x17, p.Platform)
+	msg.AddString(0x18, p.Identity)
+}
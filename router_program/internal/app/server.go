@@ -0,0 +1,536 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"router/internal/config"
+	"router/internal/events"
+	"router/internal/log"
+	"router/internal/metrics"
+	"router/internal/proxyproto"
+	"router/internal/record"
+	"router/pkg/winbox"
+)
+
+// ErrServerClosed 在 Shutdown 之后由 Serve 返回
+var ErrServerClosed = errors.New("app: server closed")
+
+// Hooks 是会话各阶段的回调, 未设置的回调会被忽略
+// 回调在会话所在的 goroutine 中执行, 不应阻塞
+type Hooks struct {
+	OnConnect    func(conn net.Conn)
+	OnRequest    func(conn net.Conn, msg *winbox.Message)
+	OnLogin      func(conn net.Conn, user string, success bool)
+	OnDisconnect func(conn net.Conn)
+}
+
+// Server 是可以嵌入其它程序的 winbox 模拟服务
+type Server struct {
+	binds      []config.Listener
+	err        error // 选项中的错误, 由 Listen 返回
+	configPath string
+	config     *config.Manager
+	recordDir  string
+	persona    Persona
+	logger     *slog.Logger
+	hooks      Hooks
+	events     *events.Stream
+	metrics    *metrics.Metrics
+
+	mu        sync.Mutex
+	listeners map[net.Listener]string // 监听 -> persona 名称
+	conns     map[net.Conn]struct{}
+	perIP     map[netip.Addr]int
+	rejected  map[string]uint64
+	sessions  map[uint64]*liveSession
+	finished  []*liveSession // 最近结束的会话
+	closed    bool
+	wg        sync.WaitGroup
+
+	nextID atomic.Uint64 // 连接编号
+}
+
+// Option 用于配置 Server
+type Option func(*Server)
+
+// WithAddr 添加监听地址, 格式见 config.ParseListen, 例如 [::]:8291, 可以多次使用
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		l, err := config.ParseListen(addr)
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.binds = append(s.binds, l)
+	}
+}
+
+// WithListen 添加监听地址, 可以多次使用
+// 没有使用 WithAddr、WithListen 和 WithListener 时使用配置文件中的 listeners
+func WithListen(l config.Listener) Option {
+	return func(s *Server) { s.binds = append(s.binds, l) }
+}
+
+// WithListener 使用已经建立的监听, 例如测试中的 127.0.0.1:0
+func WithListener(l net.Listener) Option {
+	return func(s *Server) { s.listeners[l] = "" }
+}
+
+// WithListenerPersona 使用已经建立的监听, 该监听上的连接使用 persona 指定的设备信息
+func WithListenerPersona(l net.Listener, persona string) Option {
+	return func(s *Server) { s.listeners[l] = persona }
+}
+
+// WithConfig 指定配置文件路径, 配置在 Listen 时加载
+func WithConfig(path string) Option {
+	return func(s *Server) { s.configPath = path }
+}
+
+// WithConfigManager 使用已经加载的配置, 优先于 WithConfig
+func WithConfigManager(m *config.Manager) Option {
+	return func(s *Server) { s.config = m }
+}
+
+// WithRecordDir 把每个会话录制到 dir 目录下
+func WithRecordDir(dir string) Option {
+	return func(s *Server) { s.recordDir = dir }
+}
+
+// WithPersona 指定登录后回复的设备信息
+func WithPersona(p Persona) Option {
+	return func(s *Server) { s.persona = p }
+}
+
+// WithLogger 指定日志, 默认为 log.Slog
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) { s.logger = l }
+}
+
+// WithHooks 指定会话回调
+func WithHooks(h Hooks) Option {
+	return func(s *Server) { s.hooks = h }
+}
+
+// WithMetrics 指定统计指标, 默认由 NewServer 创建, 可通过 Metrics 获取
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) { s.metrics = m }
+}
+
+// WithEvents 指定安全事件的输出, 默认不输出
+func WithEvents(stream *events.Stream) Option {
+	return func(s *Server) { s.events = stream }
+}
+
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		persona:   DefaultPersona,
+		logger:    log.Slog,
+		metrics:   metrics.New(),
+		listeners: make(map[net.Listener]string),
+		conns:     make(map[net.Conn]struct{}),
+		perIP:     make(map[netip.Addr]int),
+		rejected:  make(map[string]uint64),
+		sessions:  make(map[uint64]*liveSession),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if s.metrics == nil {
+		s.metrics = metrics.New()
+	}
+	return s
+}
+
+// Listen 加载配置并打开所有监听, Serve 会在需要时自动调用
+// 任何一个地址监听失败时关闭本次打开的监听并返回错误
+func (s *Server) Listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if s.config == nil {
+		m, err := config.NewManager(s.configPath, s.logger)
+		if err != nil {
+			return err
+		}
+		s.config = m
+	}
+	if len(s.binds) == 0 && len(s.listeners) == 0 {
+		s.binds = s.config.Current().Listeners
+	}
+
+	conf := s.config.Current()
+	for _, b := range s.binds {
+		if _, ok := conf.Personas[b.Persona]; b.Persona != "" && !ok {
+			return fmt.Errorf("listen %s: persona %q is not defined in the config", b, b.Persona)
+		}
+		if b.ProxyProtocol && len(conf.TrustedPrefixes) == 0 {
+			return fmt.Errorf("listen %s: PROXY protocol needs trustedProxies in the config", b)
+		}
+	}
+
+	opened := make(map[net.Listener]string)
+	for _, b := range s.binds {
+		l, err := net.Listen(b.Network(), b.Addr())
+		if err != nil {
+			for l := range opened {
+				l.Close()
+			}
+			return fmt.Errorf("listen %s: %w", b, err)
+		}
+		if b.ProxyProtocol {
+			// 每个连接都按当前配置判断来源是否可信
+			l = proxyproto.NewListener(l, func(addr netip.Addr) bool {
+				return s.config.Current().TrustedProxy(addr)
+			})
+		}
+		opened[l] = b.Persona
+	}
+	for l, persona := range opened {
+		s.listeners[l] = persona
+		s.logger.Info("服务器已启动，正在监听 : ", "addr", l.Addr().String(), "persona", persona)
+	}
+	s.binds = nil
+	return nil
+}
+
+// Addrs 返回正在监听的地址
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var addrs []net.Addr
+	for l := range s.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// ActiveConns 返回当前的连接数
+func (s *Server) ActiveConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Metrics 返回统计指标
+func (s *Server) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+// Serve 接受连接直到 Shutdown 或 ctx 被取消
+// ctx 被取消时会立即关闭所有连接, 需要等待会话结束时使用 Shutdown
+func (s *Server) Serve(ctx context.Context) error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if len(s.listeners) == 0 {
+		s.mu.Unlock()
+		return errors.New("app: no listeners")
+	}
+	errs := make(chan error, len(s.listeners))
+	for l, persona := range s.listeners {
+		go func(l net.Listener, persona string) { errs <- s.acceptLoop(l, persona) }(l, persona)
+	}
+	count := len(s.listeners)
+	s.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		s.close()
+		s.closeConns()
+	})
+	defer stop()
+
+	var first error
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			// 一个监听出错时停止其它监听
+			s.close()
+		}
+	}
+	if first != nil {
+		return first
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrServerClosed
+}
+
+// Shutdown 停止接受新连接, 并等待已有会话结束
+// ctx 到期时强制关闭剩余的连接并返回 ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.closeConns()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Reload 重新加载配置文件, 新连接使用新的配置, 已有会话不受影响
+// 配置无效时继续使用原配置
+func (s *Server) Reload() error {
+	s.mu.Lock()
+	m := s.config
+	s.mu.Unlock()
+	if m == nil {
+		return nil
+	}
+	return m.Reload()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// close 关闭所有监听
+func (s *Server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) acceptLoop(l net.Listener, persona string) error {
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			// 临时错误(如文件描述符耗尽)时退避重试
+			if isTemporary(err) {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				s.logger.Warn("接受连接失败, 稍后重试", "err", err.Error(), "delay", delay.String())
+				time.Sleep(delay)
+				continue
+			}
+			s.logger.Error("接受连接失败:", "err", err.Error())
+			return err
+		}
+		delay = 0
+
+		reason, err := s.track(conn, s.config.Current().Limits.MaxConns)
+		if err != nil {
+			conn.Close()
+			return nil
+		}
+		if reason != "" {
+			// reject 可能要等待 PROXY 头部, 不阻塞接受连接
+			go s.reject(conn, reason)
+			continue
+		}
+		go s.handleConn(conn, l.Addr().String(), persona)
+	}
+}
+
+func isTemporary(err error) bool {
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
+
+// track 记录新连接, 连接数已达到 maxConns 时返回拒绝的原因, 服务已关闭时返回 ErrServerClosed
+// 检查和记录在同一次加锁中完成, 同时接受的连接不会超过 maxConns; maxConns 为 0 时不限制
+func (s *Server) track(conn net.Conn, maxConns int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", ErrServerClosed
+	}
+	if maxConns > 0 && len(s.conns) >= maxConns {
+		return RejectMaxConns, nil
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return "", nil
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// handleConn 处理客户端请求, listener 为接受连接的监听地址
+func (s *Server) handleConn(conn net.Conn, listener, persona string) {
+	tracked := conn
+	defer s.untrack(tracked)
+
+	// 先读取 PROXY 头部, 之后 RemoteAddr 返回真实的客户端地址
+	if pc, ok := conn.(*proxyproto.Conn); ok {
+		if err := pc.Handshake(); err != nil {
+			s.logger.Warn("读取 PROXY 头部失败", "proxy", pc.Proxy().String(), "err", err.Error())
+			conn.Close()
+			return
+		}
+		if h, ok := pc.Header(); ok {
+			s.logger.Info("PROXY 头部", "client", conn.RemoteAddr().String(), "proxy", pc.Proxy().String(), "version", h.Version, "local", h.Local)
+		}
+	}
+
+	// 会话中的每条日志都带有连接编号和客户端地址
+	host, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	id := s.nextID.Add(1)
+	logger := s.logger.With("conn", id, "client", host, "port", port, "listener", listener)
+	portNum, _ := strconv.Atoi(port)
+	session := events.Event{Conn: id, Client: host, Port: portNum, Listener: listener}
+
+	conf := s.config.Current()
+	limits := conf.Limits
+	ip := remoteIP(conn)
+	if conf.Access.Listed(ip) {
+		switch conf.Access.Mode() {
+		case config.ActionReset:
+			resetConn(conn)
+			s.reject(conn, RejectAccess)
+			return
+		case config.ActionPersona:
+			logger.Info("来源地址被访问控制规则匹配, 使用替代设备信息", "persona", conf.Access.Persona)
+			logger = logger.With("filtered", true)
+			session.Filtered = true
+			persona = conf.Access.Persona
+		default:
+			s.reject(conn, RejectAccess)
+			return
+		}
+	}
+	if !s.acquireIP(ip, limits.MaxConnsPerIP) {
+		s.reject(conn, RejectMaxConnsPerIP)
+		return
+	}
+	defer s.releaseIP(ip)
+
+	s.metrics.ConnectionsTotal.Inc(listener)
+	s.metrics.ConnectionsActive.Inc(listener)
+	defer s.metrics.ConnectionsActive.Dec(listener)
+
+	sc := newSessionConn(conn, limits)
+	conn = sc
+
+	if s.recordDir != "" {
+		rc, err := record.Wrap(conn, s.recordDir)
+		if err != nil {
+			logger.Error("创建录制文件失败", "err", err.Error())
+		} else {
+			conn = rc
+		}
+	}
+	defer conn.Close()
+
+	td := s.newTransmissionData(conn, persona, logger, session)
+	start := time.Now()
+	live := &liveSession{
+		info: SessionInfo{ID: id, Client: host, Port: portNum, Listener: listener, Persona: td.session.Persona,
+			Filtered: session.Filtered, State: stateName(td.m_state), Started: start},
+		conn: tracked,
+		sc:   sc,
+	}
+	td.live = live
+	td.logger = slog.New(&debugHandler{td.logger.Handler(), &live.debug})
+	s.addSession(live)
+	defer s.removeSession(id)
+
+	td.logger.Info("会话开始")
+	td.emit(events.Event{Type: events.ConnectionOpen})
+	defer func() {
+		read, written, exceeded := sc.counts()
+		s.metrics.BytesReceived.Add(float64(read), listener)
+		s.metrics.BytesSent.Add(float64(written), listener)
+		s.metrics.SessionDuration.Observe(time.Since(start).Seconds(), listener)
+		closed := events.Event{Type: events.ConnectionClose, DurationMs: time.Since(start).Milliseconds(),
+			BytesIn: read, BytesOut: written, Requests: td.requests, Replies: td.replies}
+		if exceeded {
+			td.logger.Warn("会话超出字节限制, 已关闭", "maxBytes", limits.MaxBytes)
+			closed.Reason = "max_bytes"
+		}
+		if live.killed.Load() {
+			td.logger.Info("会话被管理接口关闭")
+			closed.Reason = "killed"
+		}
+		td.logger.Info("会话结束", "duration", time.Since(start).String(), "read", read, "written", written,
+			"requests", td.requests, "replies", td.replies)
+		td.emit(closed)
+	}()
+
+	if s.hooks.OnConnect != nil {
+		s.hooks.OnConnect(conn)
+	}
+	if s.hooks.OnDisconnect != nil {
+		defer s.hooks.OnDisconnect(conn)
+	}
+
+	for {
+		sc.expectRequest()
+		if !td.HandlerProcess() {
+			break
+		}
+	}
+}
+
+func (s *Server) newTransmissionData(conn net.Conn, persona string, logger *slog.Logger, session events.Event) *TransmissionData {
+	conf := s.config.Current()
+	td := NewTransmissionData(conn, conf)
+	td.persona = s.persona
+	if persona != "" {
+		if p, ok := conf.Personas[persona]; ok {
+			td.persona = p.Merge(DefaultPersona)
+		} else {
+			logger.Warn("persona 不存在, 使用默认设备信息", "persona", persona)
+		}
+	}
+	if persona == "" {
+		persona = "default"
+	}
+	td.logger = logger.With("persona", persona)
+	td.events = s.events
+	td.metrics = s.metrics
+	td.session = session
+	td.session.Persona = persona
+	td.hooks = &s.hooks
+	return td
+}
@@ -0,0 +1,383 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"router/internal/config"
+	"router/pkg/client"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testConfig 把 conf 写入临时目录并加载, conf 为空时使用默认配置(admin/admin)
+func testConfig(t *testing.T, conf string) *config.Manager {
+	t.Helper()
+	path := ""
+	if conf != "" {
+		path = filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(conf), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := config.NewManager(path, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// serve 在后台运行 s, 测试结束时关闭并检查 Serve 的返回值
+func serve(t *testing.T, s *Server) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background()) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+}
+
+// startServer 使用 conf 在 127.0.0.1:0 上启动服务端
+func startServer(t *testing.T, conf string) (*Server, string) {
+	t.Helper()
+	l := listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, conf)), WithListener(l), WithLogger(discardLogger))
+	serve(t, s)
+	return s, l.Addr().String()
+}
+
+func dialServer(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expectClosed 等待服务端关闭连接
+func expectClosed(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := io.ReadAll(conn)
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		t.Fatal("the server did not close the connection")
+	}
+}
+
+// waitFor 等待 cond 成立, 超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeAfterShutdown(t *testing.T) {
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(listen(t)), WithLogger(discardLogger))
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := s.Serve(context.Background()); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve after Shutdown returned %v", err)
+	}
+	if err := s.Listen(); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Listen after Shutdown returned %v", err)
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	busy := listen(t)
+	defer busy.Close()
+	for _, addr := range []string{"127.0.0.1:99999", busy.Addr().String()} {
+		s := NewServer(WithConfigManager(testConfig(t, "")), WithAddr("127.0.0.1:0"), WithAddr(addr), WithLogger(discardLogger))
+		if err := s.Serve(context.Background()); err == nil {
+			t.Errorf("Serve with %s succeeded", addr)
+		}
+		if n := len(s.Addrs()); n != 0 {
+			t.Errorf("%d listeners left open after a failed Listen", n)
+		}
+	}
+}
+
+func TestServeContextCancel(t *testing.T) {
+	l := listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(l), WithLogger(discardLogger))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+
+	conn := dialServer(t, l.Addr().String())
+	login(t, conn)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve returned %v, want context.Canceled", err)
+	}
+	// 取消 ctx 会立即关闭已有的连接
+	expectClosed(t, conn)
+}
+
+// Shutdown 停止接受新连接, 但等待进行中的会话结束
+func TestShutdownDrainsSession(t *testing.T) {
+	l := listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(l), WithLogger(discardLogger))
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(context.Background()) }()
+
+	conn := dialServer(t, l.Addr().String())
+	c := client.New(conn)
+	c.Timeout = 5 * time.Second
+	if _, err := c.Login("admin", "admin"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve returned %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", l.Addr().String(), time.Second); err == nil {
+		t.Error("new connection accepted after Shutdown")
+	}
+
+	// 会话仍然可以继续请求
+	if _, err := c.ReadFile("list"); err != nil {
+		t.Fatalf("ReadFile during Shutdown: %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v before the session ended", err)
+	default:
+	}
+
+	conn.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the session ended")
+	}
+}
+
+// ctx 到期时强制关闭剩余的会话
+func TestShutdownTimeout(t *testing.T) {
+	l := listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(l), WithLogger(discardLogger))
+	go s.Serve(context.Background())
+
+	conn := dialServer(t, l.Addr().String())
+	login(t, conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown returned %v, want context.DeadlineExceeded", err)
+	}
+	if n := s.ActiveConns(); n != 0 {
+		t.Errorf("%d connections left after Shutdown", n)
+	}
+	expectClosed(t, conn)
+}
+
+// 每个监听使用自己的设备信息, IPv4 和 IPv6 同时服务
+func TestMultipleListeners(t *testing.T) {
+	v4 := listen(t)
+	v6, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback is not available:", err)
+	}
+	m := testConfig(t, `{"user": "admin", "password": "admin", "personas": {"decoy": {"board": "CCR1009"}}}`)
+	s := NewServer(WithConfigManager(m), WithListener(v4), WithListenerPersona(v6, "decoy"), WithLogger(discardLogger))
+	serve(t, s)
+
+	if n := len(s.Addrs()); n != 2 {
+		t.Fatalf("serving on %d addresses, want 2", n)
+	}
+	for _, tt := range []struct {
+		l       net.Listener
+		persona string
+	}{
+		{v4, "default"},
+		{v6, "decoy"},
+	} {
+		conn := dialServer(t, tt.l.Addr().String())
+		login(t, conn)
+		var found bool
+		for _, info := range s.Sessions() {
+			if info.Listener == tt.l.Addr().String() {
+				found = true
+				if info.Persona != tt.persona {
+					t.Errorf("session on %s uses persona %q, want %q", info.Listener, info.Persona, tt.persona)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no session on %s", tt.l.Addr())
+		}
+	}
+}
+
+// 一个监听出错时 Serve 关闭其它监听并返回错误
+func TestListenerErrorStopsServe(t *testing.T) {
+	a, b := listen(t), listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(a), WithListener(b), WithLogger(discardLogger))
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background()) }()
+	waitFor(t, "both listeners to accept", func() bool {
+		for _, l := range []net.Listener{a, b} {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				return false
+			}
+			conn.Close()
+		}
+		return true
+	})
+
+	// 直接关闭监听模拟 Accept 失败
+	a.Close()
+	if err := <-done; err == nil {
+		t.Fatal("Serve returned nil after a listener failed")
+	}
+	if _, err := net.Dial("tcp", b.Addr().String()); err == nil {
+		t.Error("the other listener is still open")
+	}
+}
+
+// lockedBuffer 供多个会话同时写入日志
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 解析 JSON 格式的日志
+func (b *lockedBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(b.buf.Bytes()), []byte("\n")) {
+		var r map[string]any
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatalf("bad log line %s: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// 会话中的每条日志都带有连接编号、客户端地址和端口, 登录后带有用户名
+func TestSessionLogAttrs(t *testing.T) {
+	var out lockedBuffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	l := listen(t)
+	s := NewServer(WithConfigManager(testConfig(t, "")), WithListener(l), WithLogger(logger))
+	serve(t, s)
+
+	// 两个会话交错进行
+	ports := make(map[string]bool)
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn := dialServer(t, l.Addr().String())
+		login(t, conn)
+		_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+		ports[port] = true
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	waitFor(t, "the sessions to end", func() bool { return s.ActiveConns() == 0 })
+	waitFor(t, "the session end records", func() bool {
+		n := 0
+		for _, r := range out.records(t) {
+			if r["msg"] == "会话结束" {
+				n++
+			}
+		}
+		return n == 2
+	})
+
+	connOfPort := make(map[string]any)
+	var ended int
+	for _, r := range out.records(t) {
+		port, ok := r["port"].(string)
+		if !ok {
+			continue // 服务端自身的日志
+		}
+		if !ports[port] {
+			t.Errorf("record %v has an unknown client port", r)
+			continue
+		}
+		if r["client"] != "127.0.0.1" || r["listener"] != l.Addr().String() || r["persona"] != "default" {
+			t.Errorf("record %v is missing the session attributes", r)
+		}
+		// 同一连接的日志使用相同的连接编号, 不同连接的编号不同
+		if id, ok := connOfPort[port]; ok && id != r["conn"] {
+			t.Errorf("port %s logged with conn %v and %v", port, id, r["conn"])
+		}
+		connOfPort[port] = r["conn"]
+
+		if r["msg"] == "会话结束" {
+			ended++
+			if r["user"] != "admin" {
+				t.Errorf("session end record %v has no user", r)
+			}
+			for _, key := range []string{"duration", "read", "written", "requests", "replies"} {
+				if _, ok := r[key]; !ok {
+					t.Errorf("session end record has no %s: %v", key, r)
+				}
+			}
+		}
+	}
+	if ended != 2 {
+		t.Errorf("%d session end records, want 2", ended)
+	}
+	ids := make(map[any]bool)
+	for _, id := range connOfPort {
+		ids[id] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("sessions logged with conn ids %v, want 2 different ids", connOfPort)
+	}
+}
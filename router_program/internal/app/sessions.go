@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 每个会话保留的最近消息数、每条消息的最大长度和保留的已结束会话数
+const (
+	maxRecentMessages = 50
+	maxMessageText    = 4096
+	maxFinished       = 100
+)
+
+// SessionInfo 是正在进行的会话的状态
+type SessionInfo struct {
+	ID       uint64     `json:"id"`
+	Client   string     `json:"client"`
+	Port     int        `json:"port"`
+	Listener string     `json:"listener"`
+	Persona  string     `json:"persona"`
+	Filtered bool       `json:"filtered,omitempty"`
+	User     string     `json:"user,omitempty"`
+	State    string     `json:"state"`
+	Started  time.Time  `json:"started"`
+	BytesIn  int64      `json:"bytesIn"`
+	BytesOut int64      `json:"bytesOut"`
+	Requests int        `json:"requests"`
+	Replies  int        `json:"replies"`
+	Debug    bool       `json:"debug"` // 该会话输出 Debug 级别的日志
+	Ended    *time.Time `json:"ended,omitempty"`
+	Last     *Message   `json:"last,omitempty"` // 最近收发的消息
+}
+
+// Message 是会话中收发的一条消息
+type Message struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // in 或 out
+	Text      string    `json:"text"`      // M2 文本格式
+}
+
+// liveSession 记录会话的状态, 由会话所在的 goroutine 更新, 管理接口读取
+type liveSession struct {
+	info   SessionInfo // 只在 mu 中访问
+	conn   net.Conn
+	sc     *sessionConn
+	debug  atomic.Bool
+	killed atomic.Bool
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (s *liveSession) setUser(user string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.info.User = user
+	s.mu.Unlock()
+}
+
+func (s *liveSession) update(state string, requests, replies int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.info.State = state
+	s.info.Requests = requests
+	s.info.Replies = replies
+	s.mu.Unlock()
+}
+
+func (s *liveSession) record(direction, text string) {
+	if s == nil {
+		return
+	}
+	if len(text) > maxMessageText {
+		text = text[:maxMessageText] + "..."
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == maxRecentMessages {
+		copy(s.messages, s.messages[1:])
+		s.messages = s.messages[:maxRecentMessages-1]
+	}
+	s.messages = append(s.messages, Message{Time: time.Now(), Direction: direction, Text: text})
+}
+
+func (s *liveSession) snapshot() SessionInfo {
+	s.mu.Lock()
+	info := s.info
+	if n := len(s.messages); n > 0 {
+		last := s.messages[n-1]
+		info.Last = &last
+	}
+	s.mu.Unlock()
+	info.BytesIn, info.BytesOut, _ = s.sc.counts()
+	info.Debug = s.debug.Load()
+	return info
+}
+
+// debugHandler 在会话打开调试时输出 Debug 级别的日志, 不受全局级别限制
+type debugHandler struct {
+	slog.Handler
+	debug *atomic.Bool
+}
+
+func (h *debugHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.debug.Load() || h.Handler.Enabled(ctx, l)
+}
+
+func (h *debugHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &debugHandler{h.Handler.WithAttrs(attrs), h.debug}
+}
+
+func (h *debugHandler) WithGroup(name string) slog.Handler {
+	return &debugHandler{h.Handler.WithGroup(name), h.debug}
+}
+
+func (s *Server) addSession(sess *liveSession) {
+	s.mu.Lock()
+	s.sessions[sess.info.ID] = sess
+	s.mu.Unlock()
+}
+
+// removeSession 把会话移到已结束的列表中, 结束后仍然可以查看最近的消息
+func (s *Server) removeSession(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[id]
+	if sess == nil {
+		return
+	}
+	delete(s.sessions, id)
+	sess.mu.Lock()
+	sess.info.State = "closed"
+	now := time.Now()
+	sess.info.Ended = &now
+	sess.mu.Unlock()
+	if len(s.finished) == maxFinished {
+		s.finished = s.finished[1:]
+	}
+	s.finished = append(s.finished, sess)
+}
+
+func (s *Server) liveSession(id uint64) *liveSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// Finished 返回最近结束的会话, 最近结束的在前
+func (s *Server) Finished() []SessionInfo {
+	s.mu.Lock()
+	list := slices.Clone(s.finished)
+	s.mu.Unlock()
+
+	infos := make([]SessionInfo, len(list))
+	for i, sess := range list {
+		infos[len(list)-1-i] = sess.snapshot()
+	}
+	return infos
+}
+
+// Sessions 返回正在进行的会话, 按编号排序
+func (s *Server) Sessions() []SessionInfo {
+	s.mu.Lock()
+	list := make([]*liveSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		list = append(list, sess)
+	}
+	s.mu.Unlock()
+
+	infos := make([]SessionInfo, len(list))
+	for i, sess := range list {
+		infos[i] = sess.snapshot()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Session 返回会话的状态和最近收发的消息, 包括最近结束的会话
+func (s *Server) Session(id uint64) (SessionInfo, []Message, bool) {
+	sess := s.liveSession(id)
+	if sess == nil {
+		s.mu.Lock()
+		for _, f := range s.finished {
+			if f.info.ID == id {
+				sess = f
+			}
+		}
+		s.mu.Unlock()
+	}
+	if sess == nil {
+		return SessionInfo{}, nil, false
+	}
+	sess.mu.Lock()
+	messages := append([]Message(nil), sess.messages...)
+	sess.mu.Unlock()
+	return sess.snapshot(), messages, true
+}
+
+// KillSession 关闭会话的连接, 会话不存在时返回 false
+func (s *Server) KillSession(id uint64) bool {
+	sess := s.liveSession(id)
+	if sess == nil {
+		return false
+	}
+	sess.killed.Store(true)
+	sess.conn.Close()
+	return true
+}
+
+// SetSessionDebug 打开或关闭会话的 Debug 日志, 会话不存在时返回 false
+func (s *Server) SetSessionDebug(id uint64, enabled bool) bool {
+	sess := s.liveSession(id)
+	if sess == nil {
+		return false
+	}
+	sess.debug.Store(enabled)
+	return true
+}
@@ -1,12 +1,16 @@
 package app
 
 import (
-	"bytes"
 	"crypto/rand"
-	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"net"
+	"router/internal/config"
+	"router/internal/events"
 	"router/internal/log"
+	"router/internal/metrics"
+	"router/pkg/winbox"
+	"strconv"
 	"strings"
 )
 
@@ -20,72 +24,79 @@ const (
 )
 
 type TransmissionData struct {
-	header  []byte
-	data    []byte
-	wm      *WinboxMessage
+	reader  *winbox.Reader
+	wm      *winbox.Message
 	m_state int
 	conn    net.Conn
 	user    *User
+	persona Persona
+	logger  *slog.Logger
+	hooks   *Hooks
+
+	requests int // 收到的消息数
+	replies  int // 发送的消息数
+
+	events   *events.Stream
+	metrics  *metrics.Metrics
+	session  events.Event // 事件中的会话信息
+	username string       // 登录成功的用户名
+	openPath string       // 当前打开的文件
+	live     *liveSession // 供管理接口查看的状态, 可以为 nil
 }
 
-// TODO: Implement the constructor for TransmissionData
-func NewTransmissionData(connect net.Conn, path string) *TransmissionData {
+// NewTransmissionData 创建一个会话, conf 为会话建立时的配置快照
+func NewTransmissionData(connect net.Conn, conf *config.Config) *TransmissionData {
 	return &TransmissionData{
-		header:  make([]byte, 0),
-		data:    make([]byte, 0),
-		wm:      NewWinboxMessage(),
+		reader:  winbox.NewReader(connect),
+		wm:      winbox.NewMessage(),
 		m_state: k_none,
 		conn:    connect,
-		user:    NewUser(path),
+		user:    NewUser(conf),
+		persona: DefaultPersona,
+		logger:  log.Slog,
+		hooks:   &Hooks{},
+		metrics: metrics.New(),
 	}
 }
 
-func (t *TransmissionData) validateHeader() uint32 {
-	shortLength := uint8(t.header[0])
-	longLength := binary.BigEndian.Uint16(t.header[2:4])
-	log.Slog.Debug("传输数据长度", "shortLength", shortLength, "longLength", longLength)
-	if shortLength == 0xff {
-		return uint32(longLength)
-	}
-	if uint16(shortLength-2) != longLength {
-		return 0
-	}
-
-	return uint32(longLength)
-}
-
 func (t *TransmissionData) HandlerProcess() bool {
-
-	t.header = make([]byte, 4) // 创建一个缓冲区来存储读取的数据
-	_, err := t.conn.Read(t.header)
+	frame, err := t.reader.ReadFrame()
+	if err == winbox.ErrInvalidHeader {
+		t.logger.Warn("Invalid frame header, skipped")
+		t.metrics.DecodeErrors.Inc("invalid_header")
+		t.emit(events.Event{Type: events.ProtocolError, Reason: "invalid frame header"})
+		return true
+	}
 	if err != nil {
-		log.Slog.Error("Failed to read data:", "err", err.Error())
+		t.logger.Error("Failed to read data:", "err", err.Error())
 		return false
 	}
 
-	msg_size := t.validateHeader()
-	if msg_size != 0 {
-		t.data = make([]byte, msg_size)
-		_, err := t.conn.Read(t.data)
-		if err != nil {
-			log.Slog.Error("Failed to read data", "err", err.Error())
-			return true
-		}
-		t.wm.parseBinary(t.data)
-		log.Slog.Debug("read data pares to wm", "wm", t.wm)
-		t.handleRequest()
-	}
+	t.requests++
+	t.logger.Debug("传输数据长度", "handle", frame.Handle, "length", len(frame.Payload))
+	t.wm = winbox.Decode(frame.Payload)
+	t.logger.Debug("read data pares to wm", "wm", t.wm)
+	t.handleRequest()
+	t.live.update(stateName(t.m_state), t.requests, t.replies)
 	return true
 }
 
 func (t *TransmissionData) handleRequest() {
-	sys_to := t.wm.getU32Array(0xff0001)
+	sys_to := t.wm.GetU32Array(0xff0001)
 	if len(sys_to) == 0 {
-		log.Slog.Warn("Received a message with no system to array.")
+		t.logger.Warn("Received a message with no system to array.")
+		t.metrics.DecodeErrors.Inc("missing_sys_to")
+		t.emit(events.Event{Type: events.ProtocolError, Reason: "missing sys_to"})
 		return
 	}
 
-	log.Slog.Info(t.wm.SerializeToJson())
+	text := t.wm.SerializeToJson()
+	t.logger.Info(text)
+	t.live.record("in", text)
+	t.metrics.Messages.Inc(sysToLabel(sys_to), cmdLabel(t.wm.GetU32(0xff0007)))
+	if t.hooks.OnRequest != nil {
+		t.hooks.OnRequest(t.conn, t.wm)
+	}
 
 	if len(sys_to) == 2 && sys_to[0] == 2 && sys_to[1] == 2 {
 		t.doMproxyFileRequest()
@@ -98,193 +109,251 @@ func (t *TransmissionData) handleRequest() {
 	}
 
 	t.m_state = k_close
+	t.metrics.DecodeErrors.Inc("unsupported_request")
+	t.emit(events.Event{Type: events.ProtocolError, Reason: fmt.Sprintf("unsupported request to %v", sys_to)})
 	t.sendError()
 }
 
 func (t *TransmissionData) doMproxyFileRequest() {
-	cmd := t.wm.getU32(0x00ff0007)
-	log.Slog.Debug("doMproxyFileRequest", "cmd", cmd)
+	cmd := t.wm.GetU32(0x00ff0007)
+	t.logger.Debug("doMproxyFileRequest", "cmd", cmd)
 	if cmd == 7 { // open for reading no-auth
-		open_response := NewWinboxMessage()
+		open_response := winbox.NewMessage()
 
 		// find the path the user wants to read.
-		path := t.wm.getString(1)
+		path := t.wm.GetString(1)
 
-		log.Slog.Debug("doMproxyFileRequest", "path", path)
+		t.logger.Debug("doMproxyFileRequest", "path", path)
+		t.metrics.FileRequests.Inc(pathClass(path))
+		if exploit := exploitFor(path); exploit != "" {
+			t.logger.Warn("请求符合已知漏洞的利用方式", "path", path, "exploit", exploit)
+			t.emit(events.Event{Type: events.ExploitDetected, Path: path, Reason: exploit})
+		}
 		// handle different files differently
 		if strings.Contains(path, "index") {
-			open_response.addU32(2, uint32(len(t.user.indexContent))) // sizeof user.dat
+			open_response.AddU32(2, uint32(len(t.user.indexContent))) // sizeof user.dat
 			t.m_state = k_user_dat_open
+			t.emit(events.Event{Type: events.FileOpen, Path: path, Size: len(t.user.indexContent)})
 		} else if path == "list" {
 			// Respond with the sizeof our list file
-			open_response.addU32(2, 1798)
+			open_response.AddU32(2, uint32(len(t.user.listContent)))
 			t.m_state = k_list_open
+			t.emit(events.Event{Type: events.FileOpen, Path: path, Size: len(t.user.listContent)})
 		} else {
+			t.emit(events.Event{Type: events.FileOpen, Path: path, Reason: "not found"})
 			t.sendError()
 			return
 		}
+		t.openPath = path
 
 		// {u2:188,ufe0001:1,uff0003:2,uff0006:1,Uff0001:[],Uff0002:[2,2]}
-		open_response.addU32(0xfe0001, 1) // session id
-		if t.wm.getU32(0xff0003) != 0 {
-			open_response.addU32(0xff0003, t.wm.getU32(0xff0003)) // seq
+		open_response.AddU32(0xfe0001, 1) // session id
+		if t.wm.GetU32(0xff0003) != 0 {
+			open_response.AddU32(0xff0003, t.wm.GetU32(0xff0003)) // seq
 		}
-		open_response.addU32Array(0xff0002, t.wm.getU32Array(0xff0002)) // from
-		open_response.addU32Array(0xff0001, []uint32{})                 // to
-		open_response.addU32(0xff0006, t.wm.getU32(0xff0006))
+		open_response.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0002)) // from
+		open_response.AddU32Array(0xff0001, []uint32{})                 // to
+		open_response.AddU32(0xff0006, t.wm.GetU32(0xff0006))
 		t.sendMessagee(open_response)
 	} else if cmd == 4 { // read file
 		//conn.m_log.log(k_info, conn.m_ip, conn.m_port, "Request for file contents")
 
-		file_contents := NewWinboxMessage()
+		file_contents := winbox.NewMessage()
 
 		switch t.m_state {
 		case k_user_dat_open:
-			file_contents.addRaw(3, string(t.user.indexContent[:len(t.user.indexContent)]))
+			file_contents.AddRaw(3, string(t.user.indexContent[:len(t.user.indexContent)]))
+			t.emit(events.Event{Type: events.FileRead, Path: t.openPath, Size: len(t.user.indexContent)})
 		case k_list_open:
-			file_contents.addRaw(3, string(ListData[:1798]))
+			file_contents.AddRaw(3, string(t.user.listContent))
+			t.emit(events.Event{Type: events.FileRead, Path: t.openPath, Size: len(t.user.listContent)})
 		default:
+			t.metrics.DecodeErrors.Inc("read_without_open")
+			t.emit(events.Event{Type: events.ProtocolError, Reason: "read without open"})
 			t.sendError()
 			t.m_state = k_close
 			return
 		}
 
 		t.m_state = k_none
-		file_contents.addU32(0xfe0001, 1) // session id
-		if t.wm.getU32(0xff0003) != 0 {
-			file_contents.addU32(0xff0003, t.wm.getU32(0xff0003)) // seq
+		file_contents.AddU32(0xfe0001, 1) // session id
+		if t.wm.GetU32(0xff0003) != 0 {
+			file_contents.AddU32(0xff0003, t.wm.GetU32(0xff0003)) // seq
 		}
-		file_contents.addU32Array(0xff0002, t.wm.getU32Array(0xff0002)) // from
-		file_contents.addU32Array(0xff0001, []uint32{})                 // to
-		file_contents.addU32(0xff0006, t.wm.getU32(0xff0006))
+		file_contents.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0002)) // from
+		file_contents.AddU32Array(0xff0001, []uint32{})                 // to
+		file_contents.AddU32(0xff0006, t.wm.GetU32(0xff0006))
 		t.sendMessagee(file_contents)
 	} else if cmd == 5 { // cancel
 		// {uff0003:2,uff0006:2,Uff0001:[],Uff0002:[2,2]}
 		t.m_state = k_none
-		cancel := NewWinboxMessage()
-		cancel.addU32(0xfe0001, 1) // session id
-		if t.wm.getU32(0xff0003) != 0 {
-			cancel.addU32(0xff0003, t.wm.getU32(0xff0003)) // seq
+		cancel := winbox.NewMessage()
+		cancel.AddU32(0xfe0001, 1) // session id
+		if t.wm.GetU32(0xff0003) != 0 {
+			cancel.AddU32(0xff0003, t.wm.GetU32(0xff0003)) // seq
 		}
-		cancel.addU32Array(0xff0002, t.wm.getU32Array(0xff0002)) // from
-		cancel.addU32Array(0xff0001, []uint32{})                 // to
-		cancel.addU32(0xff0006, t.wm.getU32(0xff0006))
+		cancel.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0002)) // from
+		cancel.AddU32Array(0xff0001, []uint32{})                 // to
+		cancel.AddU32(0xff0006, t.wm.GetU32(0xff0006))
 		t.sendMessagee(cancel)
 	}
 }
 
 func (t *TransmissionData) doLoginRequest() {
-	cmd := t.wm.getU32(0xff0007)
-	log.Slog.Debug("doLoginRequest", "cmd", cmd)
+	cmd := t.wm.GetU32(0xff0007)
+	t.logger.Debug("doLoginRequest", "cmd", cmd)
 	if cmd == 4 { // hash request
 		t.m_state = k_init_login
 
-		hash_response := NewWinboxMessage()
-		hash_response.addU32(0xff0003, t.wm.getU32(0xff0003))           // seq
-		hash_response.addU32Array(0xff0002, t.wm.getU32Array(0xff0001)) // from
-		hash_response.addU32Array(0xff0001, t.wm.getU32Array(0xff0002)) // to
-		hash_response.addU32(0xff0006, t.wm.getU32(0xff0003))
+		hash_response := winbox.NewMessage()
+		hash_response.AddU32(0xff0003, t.wm.GetU32(0xff0003))           // seq
+		hash_response.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0001)) // from
+		hash_response.AddU32Array(0xff0001, t.wm.GetU32Array(0xff0002)) // to
+		hash_response.AddU32(0xff0006, t.wm.GetU32(0xff0003))
 
 		salt := make([]byte, 16)
 		_, err := rand.Read(salt)
 		if err != nil {
-			log.Slog.Error("generate salt", "err", err.Error())
+			t.logger.Error("generate salt", "err", err.Error())
 		}
-		hash_response.addRaw(9, string(salt))
+		hash_response.AddRaw(9, string(salt))
 		t.sendMessagee(hash_response)
 	} else if cmd == 1 { // login
 		//conn.m_log.log(k_info, conn.m_ip, conn.m_port, "Login request.")
-		if !t.loginValid() {
+		name := t.wm.GetString(1)
+		t.emit(events.Event{Type: events.LoginAttempt, User: name})
+		valid := t.loginValid()
+		if t.hooks.OnLogin != nil {
+			t.hooks.OnLogin(t.conn, name, valid)
+		}
+		if !valid {
+			t.logger.Warn("登录失败", "attempt", name)
+			t.metrics.LoginAttempts.Inc("failure")
+			t.emit(events.Event{Type: events.LoginFailure, User: name})
 			t.sendError()
 			return
 		}
 		t.m_state = k_logged_in
-
-		success := NewWinboxMessage()
-		success.addU32(0xfe0001, 1)                               // session id
-		success.addU32(0xff0003, t.wm.getU32(0xff0003))           // seq
-		success.addU32Array(0xff0002, t.wm.getU32Array(0xff0001)) // from
-		success.addU32Array(0xff0001, t.wm.getU32Array(0xff0002)) // to
-		success.addU32(0xff0006, t.wm.getU32(0xff0003))
-		success.addBoolean(0x13, false)
-		success.addU32(0xb, 52486)
-		success.addU32(0xf, 0)
-		success.addU32(0x10, 4)
-		success.addString(0x11, "mips")
-		success.addString(0x12, "952-hb")
-		success.addString(0x14, "")
-		success.addString(0x15, "RB952Ui-5ac2nD")
-		success.addString(0x16, "3.11")
-		success.addString(0x17, "RB700")
-		success.addString(0x18, "default")
+		t.username = name
+		t.live.setUser(name)
+		t.logger = t.logger.With("user", name)
+		t.logger.Info("登录成功")
+		t.metrics.LoginAttempts.Inc("success")
+		t.emit(events.Event{Type: events.LoginSuccess})
+
+		success := winbox.NewMessage()
+		success.AddU32(0xfe0001, 1)                               // session id
+		success.AddU32(0xff0003, t.wm.GetU32(0xff0003))           // seq
+		success.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0001)) // from
+		success.AddU32Array(0xff0001, t.wm.GetU32Array(0xff0002)) // to
+		success.AddU32(0xff0006, t.wm.GetU32(0xff0003))
+		addPersona(success, &t.persona)
 		t.sendMessagee(success)
 	}
 }
 
 func (t *TransmissionData) loginValid() bool {
-	salt := t.wm.getRaw(9)
-	log.Slog.Debug("user and passward valid", "input", t.wm.getRaw(10), "real", t.user.ValidPassward(salt))
-	return t.wm.getRaw(10) == t.user.ValidPassward(salt)
+	salt := t.wm.GetRaw(9)
+	t.logger.Debug("user and passward valid", "input", t.wm.GetRaw(10), "real", t.user.ValidPassward(salt))
+	return t.wm.GetRaw(10) == t.user.ValidPassward(salt)
 }
 
-func (t *TransmissionData) sendMessagee(pMsg *WinboxMessage) bool {
-	serialized := pMsg.SerializeToBinary()
-
+func (t *TransmissionData) sendMessagee(pMsg *winbox.Message) bool {
 	// each message starts with M2 (message format 2) identifier
-	message := append([]byte("M2"), serialized...)
-
-	if len(message) > 0xffff {
-		fmt.Println("Winbox message oversized")
+	request, err := winbox.EncodeFrame(winbox.HandleDefault, pMsg.Marshal())
+	if err != nil {
+		t.logger.Error("Winbox message oversized")
 		return false
 	}
 
-	msgSize := []byte{
-		byte(len(message) >> 8),   // 0: upper byte
-		byte(len(message) & 0xff), // 1: lower byte
-	}
-
-	var request bytes.Buffer
-
-	if len(message) < 0xfe {
-		request.WriteByte(byte(msgSize[1] + 2))
-		request.WriteByte(0x01)
-		request.Write(msgSize)
-		request.Write(message)
-	} else {
-		request.WriteByte(0xff)
-		request.WriteByte(0x01)
-		request.Write(msgSize)
-		request.Write(message[:0xfd]) // 0xff-2, because we write 2 bytes above
-		for i := 0xfd; i < len(message); i += 0xff {
-			var remain byte
-			if len(message)-i > 0xff {
-				remain = 0xff
-			} else {
-				remain = byte(len(message) - i)
-			}
-			request.WriteByte(remain)
-			request.WriteByte(0xff)
-			request.Write(message[i : i+int(remain)])
-		}
-	}
-
-	_, err := t.conn.Write(request.Bytes())
+	_, err = t.conn.Write(request)
 	if err != nil {
-		log.Slog.Error("Error writing response", "err", err.Error())
+		t.logger.Error("Error writing response", "err", err.Error())
 		return false
 	}
 
-	log.Slog.Info("sendmessage", "value", pMsg.SerializeToJson())
+	t.replies++
+	text := pMsg.SerializeToJson()
+	t.logger.Info("sendmessage", "value", text)
+	t.live.record("out", text)
 	return true
 }
 
 func (t *TransmissionData) sendError() {
 	// respond with an error message and exit
 	// {uff0003:2,uff0004:2,uff0006:1,uff0008:16646153,Uff0001:[],Uff0002:[2,2]}
-	eWM := NewWinboxMessage()
-	eWM.addU32Array(0xff0002, t.wm.getU32Array(0xff0001)) // from
-	eWM.addU32Array(0xff0001, t.wm.getU32Array(0xff0002)) // to
-	eWM.addU32(0xff0008, 16646153)
-	eWM.addU32(0xff0006, t.wm.getU32(0xff0006))
+	eWM := winbox.NewMessage()
+	eWM.AddU32Array(0xff0002, t.wm.GetU32Array(0xff0001)) // from
+	eWM.AddU32Array(0xff0001, t.wm.GetU32Array(0xff0002)) // to
+	eWM.AddU32(0xff0008, 16646153)
+	eWM.AddU32(0xff0006, t.wm.GetU32(0xff0006))
 	t.sendMessagee(eWM)
 }
+
+// emit 发送一条带有会话信息的事件
+func (t *TransmissionData) emit(e events.Event) {
+	e.Conn = t.session.Conn
+	e.Client = t.session.Client
+	e.Port = t.session.Port
+	e.Listener = t.session.Listener
+	e.Persona = t.session.Persona
+	e.Filtered = t.session.Filtered
+	if e.User == "" {
+		e.User = t.username
+	}
+	t.events.Emit(e)
+}
+
+// exploitFor 返回 path 符合的已知漏洞, 不符合时返回空字符串
+// CVE-2018-14847: 未登录时通过 mproxy 打开文件, 用 ../ 读取 /flash/rw/store/user.dat
+func exploitFor(path string) string {
+	if strings.Contains(path, "..") || strings.Contains(path, "user.dat") {
+		return "CVE-2018-14847"
+	}
+	return ""
+}
+
+// stateName 返回管理接口中显示的会话状态
+func stateName(state int) string {
+	switch state {
+	case k_user_dat_open, k_list_open:
+		return "file_open"
+	case k_init_login:
+		return "login"
+	case k_logged_in:
+		return "logged_in"
+	case k_close:
+		return "closing"
+	}
+	return "idle"
+}
+
+// sysToLabel 返回指标中 sys_to 的标签, 只区分已处理的地址, 避免客户端制造大量序列
+func sysToLabel(sys_to []uint32) string {
+	if len(sys_to) == 2 && (sys_to[0] == 2 && sys_to[1] == 2 || sys_to[0] == 13 && sys_to[1] == 4) {
+		return fmt.Sprintf("%d,%d", sys_to[0], sys_to[1])
+	}
+	return "other"
+}
+
+func cmdLabel(cmd uint32) string {
+	if cmd < 100 {
+		return strconv.Itoa(int(cmd))
+	}
+	return "other"
+}
+
+// pathClass 返回指标中文件路径的分类
+func pathClass(path string) string {
+	switch {
+	case exploitFor(path) != "":
+		return "exploit"
+	case strings.Contains(path, "index"):
+		return "index"
+	case path == "list":
+		return "list"
+	case strings.HasSuffix(path, ".jg") || strings.HasSuffix(path, ".jg.gz"):
+		return "plugin"
+	}
+	return "other"
+}
@@ -2,73 +2,26 @@ package app
 
 import (
 	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
-	"fmt"
-	"io"
-	"os"
-	"router/internal/log"
+	"router/internal/config"
 )
 
-type Config struct {
-	User       string `json:"user"`
-	Passward   string `json:"passward"`
-	IndexValue string `json:indexValue`
-}
-
 type User struct {
-	congPath     string
-	conf         Config
+	conf         *config.Config
 	indexContent []byte
+	listContent  []byte
 }
 
-func NewUser(path string) *User {
-	var user User
-	user.congPath = path
-	err := user.initConfig()
-	if err != nil {
-		return &User{
-			congPath: path,
-			conf: Config{
-				User:       "admin",
-				Passward:   "admin",
-				IndexValue: "",
-			},
-			indexContent: []byte{},
-		}
-	}
-	return &user
-}
-
-func (u *User) initConfig() error {
-	// 打开文件
-	file, err := os.Open(u.congPath)
-	if err != nil {
-		log.Slog.Error("Failed to open config file:", "err", err.Error(), "path", u.congPath)
-		return err
+// NewUser 根据配置快照创建会话使用的账号和文件内容
+func NewUser(conf *config.Config) *User {
+	user := User{
+		conf:         conf,
+		indexContent: conf.Index,
+		listContent:  conf.List,
 	}
-	defer file.Close()
-
-	// 读取文件内容
-	content, err := io.ReadAll(file)
-	if err != nil {
-		log.Slog.Error("Failed to read config file:", "err", err.Error())
-		return err
-	}
-
-	err = json.Unmarshal(content, &u.conf)
-	if err != nil {
-		log.Slog.Error("Failed to unmarshal JSON:", "err", err.Error())
-		return err
+	if user.listContent == nil {
+		user.listContent = ListData
 	}
-
-	hexStr := fmt.Sprintf("%x", u.conf.IndexValue)
-	u.indexContent, err = hex.DecodeString(hexStr)
-	if err != nil {
-		log.Slog.Error("Failed to decode hex", "err", err.Error())
-		return err
-	}
-	return nil
+	return &user
 }
 
 func (u *User) ValidPassward(salt string) string {
@@ -77,7 +30,7 @@ func (u *User) ValidPassward(salt string) string {
 
 	hash := md5.New()
 	hash.Write(one)
-	hash.Write([]byte(u.conf.Passward))
+	hash.Write([]byte(u.conf.Password))
 	hash.Write([]byte(salt))
 	hashed := hash.Sum(nil)
 
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 访问控制列表匹配到的来源的处理方式
+const (
+	ActionDrop    = "drop"    // 直接关闭连接
+	ActionReset   = "reset"   // 发送 RST 关闭连接
+	ActionPersona = "persona" // 使用 Access.Persona 指定的设备信息正常服务, 会话标记为 filtered
+)
+
+// Access 是按来源地址过滤连接的规则
+// 来源在 deny 中, 或 allow 不为空且来源不在 allow 中时, 按 action 处理
+type Access struct {
+	Allow      []string `json:"allow,omitempty"`      // CIDR 或 IP
+	AllowFiles []string `json:"allowFiles,omitempty"` // 每行一个 CIDR 或 IP, # 开头为注释, 相对路径以配置文件所在目录为准
+	Deny       []string `json:"deny,omitempty"`
+	DenyFiles  []string `json:"denyFiles,omitempty"`
+	Action     string   `json:"action,omitempty"`  // drop(默认)、reset 或 persona
+	Persona    string   `json:"persona,omitempty"` // action 为 persona 时使用的 personas 名称
+
+	AllowPrefixes []netip.Prefix `json:"-"`
+	DenyPrefixes  []netip.Prefix `json:"-"`
+}
+
+// Listed 判断来源地址是否被规则匹配
+func (a *Access) Listed(addr netip.Addr) bool {
+	if containsAddr(a.DenyPrefixes, addr) {
+		return true
+	}
+	return (len(a.Allow) > 0 || len(a.AllowFiles) > 0) && !containsAddr(a.AllowPrefixes, addr)
+}
+
+// Mode 返回匹配时的处理方式
+func (a *Access) Mode() string {
+	if a.Action == "" {
+		return ActionDrop
+	}
+	return a.Action
+}
+
+// parseInline 解析配置中直接写出的地址, 返回每个错误
+func (a *Access) parseInline() []string {
+	var problems []string
+	parse := func(name string, list []string) []netip.Prefix {
+		var prefixes []netip.Prefix
+		for i, s := range list {
+			p, err := ParsePrefix(s)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %q is not an IP address or CIDR", name, i, s))
+				continue
+			}
+			prefixes = append(prefixes, p)
+		}
+		return prefixes
+	}
+	a.AllowPrefixes = parse("allow", a.Allow)
+	a.DenyPrefixes = parse("deny", a.Deny)
+
+	switch a.Mode() {
+	case ActionDrop, ActionReset:
+		if a.Persona != "" {
+			problems = append(problems, "persona is only used when action is persona")
+		}
+	case ActionPersona:
+		if a.Persona == "" {
+			problems = append(problems, "action persona needs a persona name")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("action %q must be drop, reset or persona", a.Action))
+	}
+	return problems
+}
+
+// loadFiles 读取 allowFiles 和 denyFiles
+func (a *Access) loadFiles(confPath string) error {
+	for _, f := range a.AllowFiles {
+		prefixes, err := loadPrefixFile(f, confPath)
+		if err != nil {
+			return err
+		}
+		a.AllowPrefixes = append(a.AllowPrefixes, prefixes...)
+	}
+	for _, f := range a.DenyFiles {
+		prefixes, err := loadPrefixFile(f, confPath)
+		if err != nil {
+			return err
+		}
+		a.DenyPrefixes = append(a.DenyPrefixes, prefixes...)
+	}
+	return nil
+}
+
+// loadPrefixFile 读取每行一个地址的文件, 行内 # 之后为注释
+func loadPrefixFile(path, confPath string) ([]netip.Prefix, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(confPath), path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		p, err := ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %q is not an IP address or CIDR", path, line, text)
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, scanner.Err()
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePrefix(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{" 10.0.0.1 ", "10.0.0.1/32"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"::ffff:10.0.0.1", "10.0.0.1/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+	} {
+		p, err := ParsePrefix(tt.in)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q): %v", tt.in, err)
+			continue
+		}
+		if p.String() != tt.want {
+			t.Errorf("ParsePrefix(%q) = %s, want %s", tt.in, p, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "example.com", "10.0.0.1/33", "10.0.0/8", "2001:db8::/129"} {
+		if _, err := ParsePrefix(in); err == nil {
+			t.Errorf("ParsePrefix(%q) succeeded", in)
+		}
+	}
+}
+
+func TestContainsAddr(t *testing.T) {
+	prefixes := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+	for _, tt := range []struct {
+		addr string
+		want bool
+	}{
+		{"10.255.0.1", true},
+		{"11.0.0.1", false},
+		{"::ffff:10.0.0.1", true}, // IPv4 映射的地址按 IPv4 匹配
+		{"2001:db8:1::5", true},
+		{"2001:db9::5", false},
+	} {
+		if got := containsAddr(prefixes, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("containsAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAccessListed(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		access string
+		listed []string
+		passed []string
+	}{
+		{
+			name:   "empty",
+			access: `{}`,
+			passed: []string{"10.0.0.1", "2001:db8::1"},
+		},
+		{
+			name:   "deny",
+			access: `{"deny": ["10.0.0.0/8", "2001:db8::1"]}`,
+			listed: []string{"10.1.2.3", "::ffff:10.1.2.3", "2001:db8::1"},
+			passed: []string{"192.168.1.1", "2001:db8::2"},
+		},
+		{
+			name:   "allow",
+			access: `{"allow": ["192.168.0.0/16"]}`,
+			listed: []string{"10.0.0.1", "2001:db8::1"},
+			passed: []string{"192.168.5.5", "::ffff:192.168.5.5"},
+		},
+		{
+			// deny 优先于 allow
+			name:   "allow and deny",
+			access: `{"allow": ["192.168.0.0/16"], "deny": ["192.168.1.0/24"]}`,
+			listed: []string{"192.168.1.9", "10.0.0.1"},
+			passed: []string{"192.168.2.9"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, issues := Check([]byte(`{"user": "a", "password": "b", "access": ` + tt.access + `}`))
+			if issues.HasErrors() {
+				t.Fatal(issues)
+			}
+			for _, addr := range tt.listed {
+				if !c.Access.Listed(netip.MustParseAddr(addr)) {
+					t.Errorf("%s is not listed", addr)
+				}
+			}
+			for _, addr := range tt.passed {
+				if c.Access.Listed(netip.MustParseAddr(addr)) {
+					t.Errorf("%s is listed", addr)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessMode(t *testing.T) {
+	for _, tt := range []struct {
+		access string
+		mode   string
+		err    string
+	}{
+		{`{}`, ActionDrop, ""},
+		{`{"action": "reset"}`, ActionReset, ""},
+		{`{"action": "persona", "persona": "decoy"}`, ActionPersona, ""},
+		{`{"action": "persona"}`, "", "needs a persona name"},
+		{`{"action": "persona", "persona": "missing"}`, "", `persona "missing" is not defined`},
+		{`{"persona": "decoy"}`, "", "only used when action is persona"},
+		{`{"action": "block"}`, "", "must be drop, reset or persona"},
+		{`{"deny": ["10.0.0.300"]}`, "", "is not an IP address or CIDR"},
+	} {
+		conf := `{"user": "a", "password": "b", "personas": {"decoy": {}}, "access": ` + tt.access + `}`
+		c, issues := Check([]byte(conf))
+		if tt.err != "" {
+			if !issues.HasErrors() || !strings.Contains(issues.Error(), tt.err) {
+				t.Errorf("access %s: issues %v, want %q", tt.access, issues, tt.err)
+			}
+			continue
+		}
+		if issues.HasErrors() {
+			t.Errorf("access %s: %v", tt.access, issues)
+			continue
+		}
+		if got := c.Access.Mode(); got != tt.mode {
+			t.Errorf("access %s: mode %s, want %s", tt.access, got, tt.mode)
+		}
+	}
+}
+
+func TestAccessFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("allow.txt", "# office\n192.168.0.0/16\n\n10.0.0.1 # vpn\n")
+	write("deny.txt", "192.168.66.0/24\n")
+	write("config.json", `{"user": "a", "password": "b", "access": {"allowFiles": ["allow.txt"], "denyFiles": ["deny.txt"]}}`)
+
+	c, err := Load(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"192.168.1.1":  false,
+		"10.0.0.1":     false,
+		"10.0.0.2":     true,
+		"192.168.66.1": true,
+	} {
+		if got := c.Access.Listed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Listed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	write("deny.txt", "192.168.66.0/24\nnot-an-address\n")
+	if _, err := Load(filepath.Join(dir, "config.json")); err == nil || !strings.Contains(err.Error(), "deny.txt") {
+		t.Errorf("Load with a bad deny file returned %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Admin 是本机管理接口的监听和认证, 监听地址修改后需要重启, 令牌重新加载后立即生效
+type Admin struct {
+	Listen    string `json:"listen,omitempty"`    // unix:/path 或回环地址 host:port, 为空时不监听; 命令行中的 -admin 优先
+	Token     string `json:"token,omitempty"`     // 请求需要带有 Authorization: Bearer <token>
+	TokenFile string `json:"tokenFile,omitempty"` // 从文件读取令牌, 相对路径以配置文件所在目录为准
+
+	Secret string `json:"-"` // Token 或 TokenFile 的内容
+}
+
+// UnixSocket 返回 unix socket 的路径, 监听 TCP 时返回空字符串
+func (a Admin) UnixSocket() string {
+	path, _ := strings.CutPrefix(a.Listen, "unix:")
+	if path == a.Listen {
+		return ""
+	}
+	return path
+}
+
+func (a Admin) check() []string {
+	var problems []string
+	if a.Token != "" && a.TokenFile != "" {
+		problems = append(problems, "use either token or tokenFile")
+	}
+	if a.Listen == "" || a.UnixSocket() != "" {
+		return problems
+	}
+	if err := CheckAdminListen(a.Listen); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if a.Token == "" && a.TokenFile == "" {
+		problems = append(problems, "token or tokenFile is required when listening on TCP")
+	}
+	return problems
+}
+
+// CheckAdminListen 检查 TCP 监听地址是否为回环地址
+func CheckAdminListen(listen string) error {
+	if _, ok := strings.CutPrefix(listen, "unix:"); ok {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("listen %q must be unix:/path or host:port", listen)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("listen %q must be a loopback address", listen)
+	}
+	return nil
+}
+
+// loadToken 读取 tokenFile
+func (a *Admin) loadToken(confPath string) error {
+	a.Secret = a.Token
+	if a.TokenFile == "" {
+		return nil
+	}
+	path := a.TokenFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(confPath), path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	a.Secret = strings.TrimSpace(string(content))
+	if a.Secret == "" {
+		return fmt.Errorf("%s is empty", path)
+	}
+	return nil
+}
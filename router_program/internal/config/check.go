@@ -0,0 +1,401 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"router/internal/log"
+	"router/pkg/winbox"
+)
+
+// Version 是当前的配置格式版本
+// 0: 没有 version 字段的旧格式, 口令字段名为 passward, indexValue4 为 indexValue 的十六进制副本
+// 1: 口令字段名为 password, 去掉 indexValue4
+const Version = 1
+
+// Issue 是配置检查发现的一个问题
+type Issue struct {
+	Line    int // 从 1 开始, 0 表示未知
+	Key     string
+	Message string
+	Warning bool // 警告不影响加载
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", i.Line)
+	}
+	if i.Warning {
+		b.WriteString("warning: ")
+	}
+	if i.Key != "" {
+		fmt.Fprintf(&b, "%s: ", i.Key)
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// Issues 是检查发现的问题列表, 其中有错误时可作为 error 返回
+type Issues []Issue
+
+func (is Issues) Error() string {
+	var lines []string
+	for _, i := range is {
+		if !i.Warning {
+			lines = append(lines, i.String())
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// HasErrors 判断是否有警告以外的问题
+func (is Issues) HasErrors() bool {
+	for _, i := range is {
+		if !i.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// required 是必须出现的字段
+var required = []string{"user", "password"}
+
+// renamed 是旧版本中的字段名
+var renamed = map[string]string{
+	"passward": "password",
+}
+
+type member struct {
+	raw   json.RawMessage
+	line  int
+	index int // 在对象中的位置
+}
+
+// Check 校验配置文件内容并迁移旧版本的字段名
+// 返回的配置中不包含由 Load 生成的字段; 有错误时返回的配置为 nil
+func Check(content []byte) (*Config, Issues) {
+	members, issues := readMembers(content)
+	if issues.HasErrors() {
+		return nil, issues
+	}
+
+	issues = append(issues, migrate(members)...)
+
+	c := &Config{Limits: DefaultLimits, Log: log.Defaults}
+	fields := jsonFields(c)
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return members[keys[i]].line < members[keys[j]].line })
+
+	for _, key := range keys {
+		m := members[key]
+		field, ok := fields[key]
+		if !ok {
+			issues = append(issues, Issue{Line: m.line, Key: key, Message: "unknown key" + suggest(key, fields)})
+			continue
+		}
+		if err := decodeStrict(m.raw, field.Addr().Interface()); err != nil {
+			issues = append(issues, Issue{Line: m.line, Key: key, Message: typeError(err, field.Type())})
+		}
+	}
+	for _, key := range required {
+		if _, ok := members[key]; !ok {
+			issues = append(issues, Issue{Key: key, Message: "required key is missing"})
+		}
+	}
+	issues = append(issues, c.validate(members)...)
+
+	if issues.HasErrors() {
+		return nil, issues
+	}
+	return c, issues
+}
+
+// Upgrade 把配置文件内容迁移到当前版本, 用于 check-config -w
+// 只写出文件中已有的字段(迁移后的名字), 保持原来的顺序, 不写入默认值; version 字段放在最前面
+// content 应先通过 Check 检查
+func Upgrade(content []byte) ([]byte, error) {
+	members, issues := readMembers(content)
+	if issues.HasErrors() {
+		return nil, issues
+	}
+	migrate(members)
+	version, _ := json.Marshal(Version)
+	if m, ok := members["version"]; ok {
+		members["version"] = member{raw: version, line: m.line, index: m.index}
+	} else {
+		members["version"] = member{raw: version, index: -1}
+	}
+
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return members[keys[i]].index < members[keys[j]].index })
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(members[key].raw)
+	}
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "    "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// readMembers 读取顶层对象的字段和所在行
+func readMembers(content []byte) (map[string]member, Issues) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	members := make(map[string]member)
+	syntax := func(err error) (map[string]member, Issues) {
+		offset := dec.InputOffset()
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			offset = se.Offset
+		}
+		return nil, Issues{{Line: lineOf(content, offset), Message: err.Error()}}
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return syntax(err)
+	}
+	if tok != json.Delim('{') {
+		return syntax(fmt.Errorf("%w: config must be a JSON object", errUnexpected))
+	}
+
+	var issues Issues
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return syntax(err)
+		}
+		key := tok.(string)
+		line := lineOf(content, dec.InputOffset())
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return syntax(err)
+		}
+		if prev, ok := members[key]; ok {
+			issues = append(issues, Issue{Line: line, Key: key, Message: fmt.Sprintf("duplicate key, first defined on line %d", prev.line)})
+			continue
+		}
+		members[key] = member{raw: raw, line: line, index: len(members)}
+	}
+	if _, err := dec.Token(); err != nil {
+		return syntax(err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return syntax(fmt.Errorf("%w: data after the config object", errUnexpected))
+	}
+	return members, issues
+}
+
+var errUnexpected = errors.New("unexpected content")
+
+func lineOf(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// migrate 把旧版本的字段改为当前版本
+func migrate(members map[string]member) Issues {
+	version := 0
+	if m, ok := members["version"]; ok {
+		if json.Unmarshal(m.raw, &version) != nil {
+			return nil // 类型错误由字段检查报告
+		}
+	}
+	if version >= Version {
+		return nil
+	}
+
+	var issues Issues
+	for old, name := range renamed {
+		m, ok := members[old]
+		if !ok {
+			continue
+		}
+		if _, exists := members[name]; exists {
+			issues = append(issues, Issue{Line: m.line, Key: old, Message: fmt.Sprintf("old name of %s, ignored because %s is also set", name, name), Warning: true})
+		} else {
+			members[name] = m
+			issues = append(issues, Issue{Line: m.line, Key: old, Message: fmt.Sprintf("renamed to %s", name), Warning: true})
+		}
+		delete(members, old)
+	}
+
+	// indexValue4 是 indexValue 的十六进制副本
+	if m, ok := members["indexValue4"]; ok {
+		var s string
+		var decoded []byte
+		err := json.Unmarshal(m.raw, &s)
+		if err == nil {
+			decoded, err = hex.DecodeString(strings.TrimSpace(s))
+		}
+		if _, exists := members["indexValue"]; !exists && err == nil {
+			raw, _ := json.Marshal(string(decoded))
+			members["indexValue"] = member{raw: raw, line: m.line, index: m.index}
+			issues = append(issues, Issue{Line: m.line, Key: "indexValue4", Message: "decoded into indexValue", Warning: true})
+		} else {
+			issues = append(issues, Issue{Line: m.line, Key: "indexValue4", Message: "unused, dropped", Warning: true})
+		}
+		delete(members, "indexValue4")
+	}
+	return issues
+}
+
+// validate 检查字段之间和字段取值的一致性
+func (c *Config) validate(members map[string]member) Issues {
+	var issues Issues
+	line := func(key string) int { return members[key].line }
+
+	if c.Version > Version {
+		issues = append(issues, Issue{Line: line("version"), Key: "version", Message: fmt.Sprintf("version %d is newer than this program supports (%d)", c.Version, Version)})
+	} else if _, ok := members["version"]; !ok {
+		issues = append(issues, Issue{Key: "version", Message: fmt.Sprintf("missing, treated as version 0; run check-config -w to upgrade the file to version %d", Version), Warning: true})
+	}
+	if _, ok := members["user"]; ok && c.User == "" {
+		issues = append(issues, Issue{Line: line("user"), Key: "user", Message: "must not be empty"})
+	}
+	if len(c.IndexValue) > winbox.MaxSize {
+		issues = append(issues, Issue{Line: line("indexValue"), Key: "indexValue", Message: fmt.Sprintf("%d bytes, must be at most %d", len(c.IndexValue), winbox.MaxSize)})
+	}
+
+	seen := make(map[string]int)
+	for i, l := range c.Listeners {
+		key := fmt.Sprintf("listeners[%d]", i)
+		if err := l.check(); err != nil {
+			issues = append(issues, Issue{Line: line("listeners"), Key: key, Message: err.Error()})
+		}
+		if _, ok := c.Personas[l.Persona]; l.Persona != "" && !ok {
+			issues = append(issues, Issue{Line: line("listeners"), Key: key, Message: fmt.Sprintf("persona %q is not defined in personas", l.Persona)})
+		}
+		if j, ok := seen[l.Network()+" "+l.Addr()]; ok {
+			issues = append(issues, Issue{Line: line("listeners"), Key: key, Message: fmt.Sprintf("same address as listeners[%d]", j)})
+		}
+		seen[l.Network()+" "+l.Addr()] = i
+	}
+	for i, t := range c.TrustedProxies {
+		p, err := ParsePrefix(t)
+		if err != nil {
+			issues = append(issues, Issue{Line: line("trustedProxies"), Key: fmt.Sprintf("trustedProxies[%d]", i), Message: fmt.Sprintf("%q is not an IP address or CIDR", t)})
+			continue
+		}
+		c.TrustedPrefixes = append(c.TrustedPrefixes, p)
+	}
+	for i, l := range c.Listeners {
+		if l.ProxyProtocol && len(c.TrustedProxies) == 0 {
+			issues = append(issues, Issue{Line: line("listeners"), Key: fmt.Sprintf("listeners[%d]", i), Message: "proxyProtocol is set but trustedProxies is empty"})
+		}
+	}
+	for _, p := range c.Limits.check() {
+		issues = append(issues, Issue{Line: line("limits"), Key: "limits", Message: p})
+	}
+	for _, p := range c.Log.Check() {
+		issues = append(issues, Issue{Line: line("log"), Key: "log", Message: p})
+	}
+	for _, p := range c.Metrics.check() {
+		issues = append(issues, Issue{Line: line("metrics"), Key: "metrics", Message: p})
+	}
+	for _, p := range c.Admin.check() {
+		issues = append(issues, Issue{Line: line("admin"), Key: "admin", Message: p})
+	}
+	for _, p := range c.Events.check() {
+		issues = append(issues, Issue{Line: line("events"), Key: "events", Message: p})
+	}
+	for _, p := range c.Access.parseInline() {
+		issues = append(issues, Issue{Line: line("access"), Key: "access", Message: p})
+	}
+	if _, ok := c.Personas[c.Access.Persona]; c.Access.Persona != "" && !ok {
+		issues = append(issues, Issue{Line: line("access"), Key: "access", Message: fmt.Sprintf("persona %q is not defined in personas", c.Access.Persona)})
+	}
+	for name := range c.Personas {
+		if name == "" {
+			issues = append(issues, Issue{Line: line("personas"), Key: "personas", Message: "persona name must not be empty"})
+		}
+	}
+	return issues
+}
+
+// jsonFields 返回结构体中可以出现在配置文件里的字段
+func jsonFields(c *Config) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// decodeStrict 解析字段的值, 嵌套对象中不允许出现未知字段
+func decodeStrict(raw json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func typeError(err error, want reflect.Type) string {
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		if te.Field != "" {
+			return fmt.Sprintf("%s: expected %s, got %s", te.Field, kindName(te.Type), te.Value)
+		}
+		return fmt.Sprintf("expected %s, got %s", kindName(want), te.Value)
+	}
+	return err.Error()
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return t.Kind().String()
+}
+
+// suggest 为拼错的字段名给出提示
+func suggest(key string, fields map[string]reflect.Value) string {
+	if name, ok := renamed[key]; ok {
+		return fmt.Sprintf(" (renamed to %s in version 1)", name)
+	}
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf(" (did you mean %s?)", name)
+		}
+	}
+	return ""
+}
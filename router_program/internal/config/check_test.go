@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestUpgrade(t *testing.T) {
+	old := `{
+  "user": "admin",
+  "indexValue4": "6869",
+  "passward": "x",
+  "limits": {"maxConns": 50}
+}`
+	want := `{
+    "version": 1,
+    "user": "admin",
+    "indexValue": "hi",
+    "password": "x",
+    "limits": {
+        "maxConns": 50
+    }
+}
+`
+	got, err := Upgrade([]byte(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Upgrade returned\n%s\nwant\n%s", got, want)
+	}
+	if _, issues := Check(got); len(issues) != 0 {
+		t.Errorf("upgraded config has issues: %v", issues)
+	}
+}
+
+func TestUpgradeKeepsVersionPosition(t *testing.T) {
+	got, err := Upgrade([]byte(`{"user": "a", "version": 0, "password": "b"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n    \"user\": \"a\",\n    \"version\": 1,\n    \"password\": \"b\"\n}\n"
+	if string(got) != want {
+		t.Errorf("Upgrade returned %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// ParsePrefix 解析 CIDR 或单个 IP 地址, 单个地址视为 /32 或 /128
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// containsAddr 判断 addr 是否在 prefixes 中, IPv4 映射的 IPv6 地址按 IPv4 处理
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+// Package config 负责加载和校验配置文件, 并在运行时安全地替换配置
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"router/internal/log"
+	"router/pkg/winbox"
+)
+
+// Config 是配置文件的内容
+type Config struct {
+	Version    int    `json:"version"`
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	IndexValue string `json:"indexValue"`
+	ListFile   string `json:"listFile,omitempty"` // list 或 JSON 格式的插件清单, 为空时使用内置清单
+
+	Listeners []Listener         `json:"listeners,omitempty"` // 为空时使用命令行指定的地址
+	Personas  map[string]Persona `json:"personas,omitempty"`  // 可在 listeners 中引用的设备信息
+
+	TrustedProxies []string `json:"trustedProxies,omitempty"` // 可以发送 PROXY 头部的负载均衡地址(CIDR 或 IP)
+
+	Limits Limits `json:"limits"`
+	Access Access `json:"access"`
+
+	Log    log.Options `json:"log"` // 命令行中的 -log-* 优先
+	Events Events      `json:"events"`
+
+	Metrics Metrics `json:"metrics"`
+	Admin   Admin   `json:"admin"`
+
+	// 以下字段由 Load 根据上面的配置生成
+	Path     string `json:"-"`
+	Index    []byte `json:"-"` // index 文件内容
+	List     []byte `json:"-"` // list 文件内容, 为 nil 时使用内置清单
+	Warnings Issues `json:"-"` // 不影响加载的问题, 例如迁移了旧的字段名
+
+	TrustedPrefixes []netip.Prefix `json:"-"` // 解析后的 TrustedProxies
+}
+
+// TrustedProxy 判断 addr 是否为可信的负载均衡地址
+func (c *Config) TrustedProxy(addr netip.Addr) bool {
+	return containsAddr(c.TrustedPrefixes, addr)
+}
+
+// Default 是未指定配置文件时使用的配置
+func Default() *Config {
+	return &Config{
+		Version:  Version,
+		User:     "admin",
+		Password: "admin",
+		Limits:   DefaultLimits,
+		Log:      log.Defaults,
+		Index:    []byte{},
+	}
+}
+
+// Load 读取并校验配置文件, 出错时不返回部分结果
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, issues := Check(content)
+	if issues.HasErrors() {
+		return nil, fmt.Errorf("%s: %w", path, issues)
+	}
+	c.Path = path
+	c.Version = Version
+	c.Warnings = issues
+	c.Index = []byte(c.IndexValue)
+
+	if c.ListFile != "" {
+		c.List, err = loadManifest(c.ListFile, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: listFile %s: %w", path, c.ListFile, err)
+		}
+	}
+	if err := c.Access.loadFiles(path); err != nil {
+		return nil, fmt.Errorf("%s: access: %w", path, err)
+	}
+	if err := c.Admin.loadToken(path); err != nil {
+		return nil, fmt.Errorf("%s: admin: %w", path, err)
+	}
+	return c, nil
+}
+
+// loadManifest 读取插件清单, 相对路径以配置文件所在目录为准
+func loadManifest(path, confPath string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(confPath), path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		var m winbox.Manifest
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, err
+		}
+		content = m.Bytes()
+	} else if _, err := winbox.ParseManifest(content); err != nil {
+		return nil, err
+	}
+	if len(content) > winbox.MaxSize {
+		return nil, winbox.ErrOversized
+	}
+	return content, nil
+}
+
+// Diff 列出两份配置之间的差异, 口令只显示是否改变
+func Diff(old, new *Config) []string {
+	var changes []string
+	diffValue(&changes, "", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem())
+	return changes
+}
+
+func diffValue(changes *[]string, prefix string, a, b reflect.Value) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		fa, fb := a.Field(i), b.Field(i)
+
+		switch {
+		case name == "-":
+			// 生成的内容只比较是否改变
+			if fa.Type() == reflect.TypeOf([]byte(nil)) && !bytes.Equal(fa.Bytes(), fb.Bytes()) {
+				*changes = append(*changes, fmt.Sprintf("%s%s content changed (%d -> %d bytes)", prefix, strings.ToLower(f.Name), fa.Len(), fb.Len()))
+			}
+		case f.Type.Kind() == reflect.Struct:
+			diffValue(changes, prefix+name+".", fa, fb)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct && fa.Len() == fb.Len():
+			// 数量不变时逐个比较, 避免输出其中的口令
+			for j := 0; j < fa.Len(); j++ {
+				diffValue(changes, fmt.Sprintf("%s%s[%d].", prefix, name, j), fa.Index(j), fb.Index(j))
+			}
+		case reflect.DeepEqual(fa.Interface(), fb.Interface()):
+		case isSecret(name):
+			*changes = append(*changes, fmt.Sprintf("%s%s changed", prefix, name))
+		default:
+			*changes = append(*changes, fmt.Sprintf("%s%s: %s -> %s", prefix, name, short(fa), short(fb)))
+		}
+	}
+}
+
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "pass") || strings.Contains(name, "secret") || strings.Contains(name, "token") ||
+		strings.Contains(name, "key") || strings.Contains(name, "header")
+}
+
+// short 格式化字段值, 过长时截断
+func short(v reflect.Value) string {
+	s := fmt.Sprint(v.Interface())
+	if v.Kind() == reflect.String {
+		s = strconv.Quote(s)
+	}
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
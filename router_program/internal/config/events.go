@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+
+	"router/internal/events"
+)
+
+// Events 是安全事件的输出, 事件格式见 router/internal/events
+type Events struct {
+	File  string      `json:"file,omitempty"`  // 每行一个 JSON 事件, 为空时不输出; 命令行中的 -events 优先
+	Sinks []EventSink `json:"sinks,omitempty"` // 发送到外部系统
+}
+
+// 外部系统的类型
+const (
+	SinkWebhook       = "webhook"       // 每批事件作为 JSON 数组 POST 到 url
+	SinkElasticsearch = "elasticsearch" // Elasticsearch/OpenSearch 的 _bulk 接口
+)
+
+// EventSink 是一个按批发送事件的外部系统, 数值为 0 时使用默认值
+type EventSink struct {
+	Name string `json:"name,omitempty"` // 用于日志和队列目录, 默认为 type 加序号, 如 webhook0
+	Type string `json:"type"`
+	URL  string `json:"url"`
+
+	Headers  map[string]string `json:"headers,omitempty"`  // webhook 请求的附加头部, 例如 Authorization
+	Index    string            `json:"index,omitempty"`    // elasticsearch 的索引或数据流, 默认 winbox-events
+	Username string            `json:"username,omitempty"` // elasticsearch 基本认证
+	Password string            `json:"password,omitempty"`
+	APIKey   string            `json:"apiKey,omitempty"` // elasticsearch ApiKey 认证
+
+	InsecureSkipVerify bool     `json:"insecureSkipVerify,omitempty"` // 不校验 https 证书
+	Types              []string `json:"types,omitempty"`              // 只发送这些类型的事件, 为空时发送全部
+
+	BatchSize     int      `json:"batchSize,omitempty"`     // 每批最多的事件数, 默认 100
+	FlushInterval Duration `json:"flushInterval,omitempty"` // 不足一批时等待的时间, 默认 1s
+	Timeout       Duration `json:"timeout,omitempty"`       // 每次请求的超时, 默认 10s
+	MaxQueue      int      `json:"maxQueue,omitempty"`      // 未发送事件的上限, 超过时丢弃新事件, 默认 10000
+	QueueDir      string   `json:"queueDir,omitempty"`      // 不为空时未发送的事件保存在该目录下的 name 子目录中, 重启后继续发送; 相对路径以配置文件所在目录为准
+}
+
+// SinkName 返回第 i 个 sink 的名称
+func (e Events) SinkName(i int) string {
+	if e.Sinks[i].Name != "" {
+		return e.Sinks[i].Name
+	}
+	return fmt.Sprintf("%s%d", e.Sinks[i].Type, i)
+}
+
+// QueuePath 返回第 i 个 sink 的队列目录, 没有配置 queueDir 时返回空字符串
+func (c *Config) QueuePath(i int) string {
+	dir := c.Events.Sinks[i].QueueDir
+	if dir == "" {
+		return ""
+	}
+	if !filepath.IsAbs(dir) && c.Path != "" {
+		dir = filepath.Join(filepath.Dir(c.Path), dir)
+	}
+	return filepath.Join(dir, c.Events.SinkName(i))
+}
+
+func (e Events) check() []string {
+	var problems []string
+	names := make(map[string]bool)
+	for i, s := range e.Sinks {
+		where := fmt.Sprintf("sinks[%d]", i)
+		name := e.SinkName(i)
+		if names[name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate name %q", where, name))
+		}
+		names[name] = true
+
+		switch s.Type {
+		case SinkWebhook:
+			if s.Index != "" || s.Username != "" || s.Password != "" || s.APIKey != "" {
+				problems = append(problems, where+": index, username, password and apiKey are only used by elasticsearch")
+			}
+		case SinkElasticsearch:
+			if len(s.Headers) > 0 {
+				problems = append(problems, where+": headers are only used by webhook")
+			}
+			if s.APIKey != "" && (s.Username != "" || s.Password != "") {
+				problems = append(problems, where+": use either apiKey or username/password")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: type %q must be %s or %s", where, s.Type, SinkWebhook, SinkElasticsearch))
+		}
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s: url %q must be an http or https URL", where, s.URL))
+		}
+		for _, t := range s.Types {
+			if !slices.Contains(events.Types, events.Type(t)) {
+				problems = append(problems, fmt.Sprintf("%s: unknown event type %q", where, t))
+			}
+		}
+		if s.BatchSize < 0 || s.MaxQueue < 0 {
+			problems = append(problems, where+": batchSize and maxQueue must not be negative")
+		}
+	}
+	return problems
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration 在配置文件中写作 "30s"、"5m" 这样的字符串, 也可以是秒数
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("bad duration %q, expected a value like \"30s\" or \"5m\"", v)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("bad duration %s, expected a value like \"30s\" or \"5m\"", data)
+	}
+	if *d < 0 {
+		return fmt.Errorf("duration %s must not be negative", data)
+	}
+	return nil
+}
+
+// Limits 限制连接数量和会话使用的资源, 值为 0 表示不限制
+type Limits struct {
+	MaxConns      int      `json:"maxConns"`      // 同时存在的连接总数
+	MaxConnsPerIP int      `json:"maxConnsPerIP"` // 每个来源地址同时存在的连接数
+	ReadTimeout   Duration `json:"readTimeout"`   // 收到请求的第一个字节后读完请求的时间
+	WriteTimeout  Duration `json:"writeTimeout"`  // 每次发送回复的时间
+	IdleTimeout   Duration `json:"idleTimeout"`   // 等待下一个请求的时间
+	MaxBytes      int64    `json:"maxBytes"`      // 每个会话收发的总字节数
+}
+
+// DefaultLimits 是配置文件中没有 limits 时使用的值, 只写了部分字段时其余字段也取这里的值
+var DefaultLimits = Limits{
+	MaxConns:      1024,
+	MaxConnsPerIP: 16,
+	ReadTimeout:   Duration(30 * time.Second),
+	WriteTimeout:  Duration(30 * time.Second),
+	IdleTimeout:   Duration(5 * time.Minute),
+	MaxBytes:      16 << 20,
+}
+
+func (l Limits) check() []string {
+	var problems []string
+	if l.MaxConns < 0 || l.MaxConnsPerIP < 0 || l.MaxBytes < 0 {
+		problems = append(problems, "maxConns, maxConnsPerIP and maxBytes must not be negative")
+	}
+	if l.MaxConns > 0 && l.MaxConnsPerIP > l.MaxConns {
+		problems = append(problems, fmt.Sprintf("maxConnsPerIP %d is larger than maxConns %d", l.MaxConnsPerIP, l.MaxConns))
+	}
+	return problems
+}
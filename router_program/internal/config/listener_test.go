@@ -0,0 +1,62 @@
+package config
+
+import "testing"
+
+func TestParseListen(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Listener
+		addr string
+	}{
+		{"127.0.0.1:8291", Listener{Address: "127.0.0.1", Port: 8291}, "127.0.0.1:8291"},
+		{":8291", Listener{Port: 8291}, ":8291"},
+		{"[::1]:8291", Listener{Address: "::1", Port: 8291}, "[::1]:8291"},
+		{"[::]:8291@decoy", Listener{Address: "::", Port: 8291, Persona: "decoy"}, "[::]:8291"},
+		{"tcp6://[2001:db8::1]:80", Listener{Address: "2001:db8::1", Port: 80, Protocol: "tcp6"}, "[2001:db8::1]:80"},
+		{"tcp4://0.0.0.0:8291", Listener{Address: "0.0.0.0", Port: 8291, Protocol: "tcp4"}, "0.0.0.0:8291"},
+		{"proxy+tcp://:8291", Listener{Port: 8291, Protocol: "tcp", ProxyProtocol: true}, ":8291"},
+		{"router.lan:8291", Listener{Address: "router.lan", Port: 8291}, "router.lan:8291"},
+	} {
+		l, err := ParseListen(tt.in)
+		if err != nil {
+			t.Errorf("ParseListen(%q): %v", tt.in, err)
+			continue
+		}
+		if l != tt.want {
+			t.Errorf("ParseListen(%q) = %+v, want %+v", tt.in, l, tt.want)
+		}
+		if l.Addr() != tt.addr {
+			t.Errorf("ParseListen(%q).Addr() = %s, want %s", tt.in, l.Addr(), tt.addr)
+		}
+	}
+
+	for _, in := range []string{
+		"8291",
+		"::1:8291",
+		"127.0.0.1:0",
+		"127.0.0.1:65536",
+		"udp://:8291",
+		"tcp4://[::1]:8291",
+		"tcp6://127.0.0.1:8291",
+	} {
+		if _, err := ParseListen(in); err == nil {
+			t.Errorf("ParseListen(%q) succeeded", in)
+		}
+	}
+}
+
+// 配置文件中的 listeners 使用 IPv6 地址时 Addr 加上方括号
+func TestListenerAddr(t *testing.T) {
+	for _, tt := range []struct {
+		l    Listener
+		want string
+	}{
+		{Listener{Address: "::1", Port: 8291}, "[::1]:8291"},
+		{Listener{Address: "[::1]", Port: 8291}, "[::1]:8291"},
+		{Listener{Port: 8291}, ":8291"},
+	} {
+		if got := tt.l.Addr(); got != tt.want {
+			t.Errorf("%+v.Addr() = %s, want %s", tt.l, got, tt.want)
+		}
+	}
+}
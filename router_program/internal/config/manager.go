@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager 持有当前生效的配置
+// 重新加载失败时保留原来的配置, 成功时原子地替换
+type Manager struct {
+	path   string
+	logger *slog.Logger
+
+	current atomic.Pointer[Config]
+
+	mu      sync.Mutex // 串行化 Reload
+	modTime time.Time
+	size    int64
+	subs    []func(old, new *Config)
+}
+
+// NewManager 加载 path 指定的配置文件, path 为空时使用 Default
+func NewManager(path string, logger *slog.Logger) (*Manager, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &Manager{path: path, logger: logger}
+	if path == "" {
+		m.current.Store(Default())
+		logger.Warn("未指定配置文件, 使用默认账号")
+		return m, nil
+	}
+
+	m.stat()
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(c)
+	logger.Info("配置已加载", "path", path)
+	m.warn(c)
+	return m, nil
+}
+
+// Path 返回配置文件路径
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Current 返回当前配置, 调用者不能修改返回值
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// Subscribe 注册配置改变时的回调, 回调在 Reload 的 goroutine 中执行
+func (m *Manager) Subscribe(fn func(old, new *Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, fn)
+}
+
+// Reload 重新加载配置文件, 出错时保留当前配置并返回错误
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.path == "" {
+		return nil
+	}
+
+	m.stat()
+	c, err := Load(m.path)
+	if err != nil {
+		m.logger.Error("配置无效, 继续使用原配置", "err", err.Error(), "path", m.path)
+		return err
+	}
+
+	m.warn(c)
+	old := m.current.Swap(c)
+	changes := Diff(old, c)
+	if len(changes) == 0 {
+		m.logger.Info("配置已重新加载, 没有变化", "path", m.path)
+		return nil
+	}
+	m.logger.Info("配置已重新加载", "path", m.path, "changes", changes)
+	for _, fn := range m.subs {
+		fn(old, c)
+	}
+	return nil
+}
+
+func (m *Manager) warn(c *Config) {
+	for _, w := range c.Warnings {
+		m.logger.Warn("配置文件", "path", m.path, "issue", w.String())
+	}
+}
+
+// Watch 每隔 interval 检查配置文件的修改时间和大小, 改变时重新加载, 直到 ctx 被取消
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	if m.path == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.changed() {
+				m.Reload()
+			}
+		}
+	}
+}
+
+// stat 记录配置文件当前的修改时间和大小, 调用者持有 mu
+func (m *Manager) stat() {
+	if fi, err := os.Stat(m.path); err == nil {
+		m.modTime, m.size = fi.ModTime(), fi.Size()
+	}
+}
+
+func (m *Manager) changed() bool {
+	fi, err := os.Stat(m.path)
+	if err != nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !fi.ModTime().Equal(m.modTime) || fi.Size() != m.size
+}
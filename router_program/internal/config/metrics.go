@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Metrics 是 Prometheus 指标的 HTTP 监听, 修改后需要重启
+type Metrics struct {
+	Listen string `json:"listen,omitempty"` // 例如 127.0.0.1:9291, 为空时不监听; 命令行中的 -metrics 优先
+	Path   string `json:"path,omitempty"`   // 默认 /metrics
+}
+
+// MetricsPath 返回指标的 URL 路径
+func (m Metrics) MetricsPath() string {
+	if m.Path == "" {
+		return "/metrics"
+	}
+	return m.Path
+}
+
+func (m Metrics) check() []string {
+	var problems []string
+	if _, _, err := net.SplitHostPort(m.Listen); m.Listen != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("listen %q must be host:port", m.Listen))
+	}
+	if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+		problems = append(problems, fmt.Sprintf("path %q must start with /", m.Path))
+	}
+	return problems
+}
@@ -0,0 +1,250 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sender 把一批事件发送到外部系统, 每个元素是一个 JSON 编码的事件
+type Sender interface {
+	Send(ctx context.Context, batch [][]byte) error
+}
+
+// PermanentError 表示重试也不会成功, 例如请求被拒绝, 这批事件会被丢弃
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError 表示一批事件中只有部分需要重试, 例如 _bulk 中部分文档因 429 失败
+// Retry 中的事件重新放入队列, 其它失败的事件(Rejected 个)被丢弃
+type PartialError struct {
+	Err      error
+	Retry    [][]byte
+	Rejected int
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// BatchOptions 是 Batcher 的设置, 值为 0 时使用默认值
+type BatchOptions struct {
+	BatchSize     int           // 每批最多的事件数, 默认 100
+	FlushInterval time.Duration // 不足一批时等待的时间, 默认 1s
+	Timeout       time.Duration // 每次发送的超时, 默认 10s
+	MaxQueue      int           // 队列中最多的事件数, 超过时丢弃新事件, 默认 10000
+	QueueDir      string        // 不为空时队列保存在该目录中, 重启后继续发送
+	Types         []Type        // 只发送这些类型的事件, 为空时发送全部
+}
+
+const maxBackoff = time.Minute
+
+// Batcher 是一个 Sink, 把事件放入队列, 在后台按批发送, 失败时退避重试
+type Batcher struct {
+	name   string
+	sender Sender
+	opts   BatchOptions
+	types  map[Type]bool
+	logger *slog.Logger
+
+	queue queue
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewBatcher 创建 Batcher 并开始发送, name 用于日志
+func NewBatcher(name string, sender Sender, opts BatchOptions, logger *slog.Logger) (*Batcher, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxQueue <= 0 {
+		opts.MaxQueue = 10000
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	b := &Batcher{
+		name:   name,
+		sender: sender,
+		opts:   opts,
+		logger: logger.With("sink", name),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if len(opts.Types) > 0 {
+		b.types = make(map[Type]bool, len(opts.Types))
+		for _, t := range opts.Types {
+			b.types[t] = true
+		}
+	}
+	if opts.QueueDir != "" {
+		q, err := openDiskQueue(opts.QueueDir, opts.MaxQueue)
+		if err != nil {
+			return nil, err
+		}
+		if n := q.len(); n > 0 {
+			b.logger.Info("队列中有未发送的事件", "pending", n, "dir", opts.QueueDir)
+		}
+		b.queue = q
+	} else {
+		b.queue = &memQueue{max: opts.MaxQueue}
+	}
+
+	go b.run()
+	return b, nil
+}
+
+// Emit 把事件放入队列, 队列满时丢弃并计数
+func (b *Batcher) Emit(e *Event) error {
+	if b.types != nil && !b.types[e.Type] {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ok, err := b.queue.push(line)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		b.mu.Lock()
+		b.dropped++
+		b.mu.Unlock()
+		return nil
+	}
+	if b.queue.len() >= b.opts.BatchSize {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close 停止发送, 最多用一次发送超时的时间发送剩余的事件
+// 使用磁盘队列时未发送的事件保留到下次启动
+func (b *Batcher) Close() error {
+	close(b.stop)
+	<-b.done
+	if n := b.queue.len(); n > 0 {
+		b.logger.Warn("关闭时仍有未发送的事件", "pending", n, "persistent", b.opts.QueueDir != "")
+	}
+	return b.queue.close()
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	delay := time.Second
+	for {
+		select {
+		case <-b.stop:
+			b.send()
+			return
+		case <-b.wake:
+		case <-ticker.C:
+		}
+
+		for b.queue.len() > 0 {
+			err := b.send()
+			if err == nil {
+				delay = time.Second
+				continue
+			}
+			b.logger.Warn("发送事件失败, 稍后重试", "err", err.Error(), "pending", b.queue.len(), "delay", delay.String())
+			select {
+			case <-b.stop:
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > maxBackoff {
+				delay = maxBackoff
+			}
+		}
+	}
+}
+
+// send 发送队列中的一批事件, 成功或遇到不可重试的错误时从队列中移除
+func (b *Batcher) send() error {
+	lines, end, err := b.queue.peek(b.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.opts.Timeout)
+	defer cancel()
+	err = b.sender.Send(ctx, lines)
+	var perm *PermanentError
+	var partial *PartialError
+	switch {
+	case errors.As(err, &perm):
+		b.logger.Error("事件被拒绝, 已丢弃", "err", err.Error(), "events", len(lines))
+	case errors.As(err, &partial):
+		if partial.Rejected > 0 {
+			b.logger.Error("部分事件被拒绝, 已丢弃", "err", err.Error(), "events", partial.Rejected)
+		}
+	case err != nil:
+		return err
+	}
+	if err := b.queue.commit(end); err != nil {
+		return fmt.Errorf("commit queue: %w", err)
+	}
+	// 需要重试的事件放回队列末尾, 返回错误以便退避后再发送
+	if partial != nil && len(partial.Retry) > 0 {
+		for _, line := range partial.Retry {
+			ok, err := b.queue.push(line)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				b.mu.Lock()
+				b.dropped++
+				b.mu.Unlock()
+			}
+		}
+		return partial
+	}
+
+	b.mu.Lock()
+	dropped := b.dropped
+	b.dropped = 0
+	b.mu.Unlock()
+	if dropped > 0 {
+		b.logger.Warn("队列已满, 丢弃了部分事件", "dropped", dropped)
+	}
+	return nil
+}
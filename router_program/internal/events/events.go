@@ -0,0 +1,166 @@
+// Package events 定义安全事件的格式, 与日志的措辞无关, 供下游工具解析
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Schema 是事件格式的版本, 只增加字段时不改变, 修改或删除字段时加一
+const Schema = 1
+
+// Type 是事件类型
+type Type string
+
+const (
+	ConnectionOpen     Type = "connection_open"     // 会话开始
+	ConnectionRejected Type = "connection_rejected" // 连接被 limits 或 access 拒绝, reason 为原因
+	ConnectionClose    Type = "connection_close"    // 会话结束, 带有持续时间、字节数和消息数
+	LoginAttempt       Type = "login_attempt"       // 收到登录请求, user 为尝试的用户名
+	LoginSuccess       Type = "login_success"
+	LoginFailure       Type = "login_failure"
+	FileOpen           Type = "file_open"        // 通过 mproxy 打开文件, path 为请求的路径
+	FileRead           Type = "file_read"        // 读取已打开的文件, size 为返回的字节数
+	ExploitDetected    Type = "exploit_detected" // 请求符合已知漏洞的利用方式, reason 为漏洞编号
+	ProtocolError      Type = "protocol_error"   // 无效的帧或无法处理的请求, reason 为原因
+)
+
+// Types 是全部事件类型
+var Types = []Type{
+	ConnectionOpen, ConnectionRejected, ConnectionClose,
+	LoginAttempt, LoginSuccess, LoginFailure,
+	FileOpen, FileRead, ExploitDetected, ProtocolError,
+}
+
+// Event 是一条安全事件, 以 JSON 对象输出, 字段名保持稳定
+type Event struct {
+	Schema   int       `json:"schema"`
+	Time     time.Time `json:"time"`
+	Type     Type      `json:"type"`
+	Conn     uint64    `json:"conn,omitempty"` // 连接编号, 与日志中的 conn 相同
+	Client   string    `json:"client"`         // 客户端地址, PROXY 协议时为真实地址
+	Port     int       `json:"port"`
+	Listener string    `json:"listener,omitempty"`
+	Persona  string    `json:"persona,omitempty"`
+	Filtered bool      `json:"filtered,omitempty"` // 来源被 access 规则匹配
+	User     string    `json:"user,omitempty"`
+
+	Path   string `json:"path,omitempty"`
+	Size   int    `json:"size,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// connection_close
+	DurationMs int64 `json:"durationMs,omitempty"`
+	BytesIn    int64 `json:"bytesIn,omitempty"`
+	BytesOut   int64 `json:"bytesOut,omitempty"`
+	Requests   int   `json:"requests,omitempty"`
+	Replies    int   `json:"replies,omitempty"`
+}
+
+// Sink 接收事件, Emit 不应阻塞
+type Sink interface {
+	Emit(e *Event) error
+	Close() error
+}
+
+// Stream 把事件交给当前的 Sink, 可以在运行时替换, nil 的 Stream 丢弃所有事件
+type Stream struct {
+	logger *slog.Logger
+
+	mu    sync.RWMutex
+	sinks []Sink
+}
+
+// NewStream 创建没有 Sink 的 Stream, Sink 出错时记录到 logger
+func NewStream(logger *slog.Logger) *Stream {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Stream{logger: logger}
+}
+
+// Emit 补充版本和时间后交给每个 Sink
+func (s *Stream) Emit(e Event) {
+	if s == nil {
+		return
+	}
+	e.Schema = Schema
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sink := range s.sinks {
+		if err := sink.Emit(&e); err != nil {
+			s.logger.Error("写入事件失败", "type", string(e.Type), "err", err.Error())
+		}
+	}
+}
+
+// Replace 替换全部 Sink 并关闭原来的 Sink
+func (s *Stream) Replace(sinks ...Sink) {
+	s.mu.Lock()
+	old := s.sinks
+	s.sinks = sinks
+	s.mu.Unlock()
+	for _, sink := range old {
+		sink.Close()
+	}
+}
+
+// Close 关闭全部 Sink
+func (s *Stream) Close() {
+	if s != nil {
+		s.Replace()
+	}
+}
+
+// File 把事件逐行写入文件(NDJSON)
+type File struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// OpenFile 以追加方式打开 path
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, f: f}, nil
+}
+
+// Path 返回文件路径
+func (f *File) Path() string {
+	return f.path
+}
+
+func (f *File) Emit(e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return errors.New("events: file closed")
+	}
+	_, err = f.f.Write(append(line, '\n'))
+	return err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
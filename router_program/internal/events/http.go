@@ -0,0 +1,159 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Webhook 把每批事件作为 JSON 数组 POST 到 URL
+type Webhook struct {
+	URL     string
+	Headers map[string]string // 例如 Authorization
+	Client  *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, batch [][]byte) error {
+	body := append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+	body = append(body, ']')
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	_, err = do(client(w.Client), req)
+	return err
+}
+
+// Elasticsearch 使用 Elasticsearch/OpenSearch 的 _bulk 接口写入 Index
+type Elasticsearch struct {
+	URL      string // 集群地址, 例如 https://es.example.com:9200
+	Index    string
+	Username string // 基本认证
+	Password string
+	APIKey   string // 不为空时使用 ApiKey 认证
+	Client   *http.Client
+}
+
+func (e *Elasticsearch) Send(ctx context.Context, batch [][]byte) error {
+	action, err := json.Marshal(map[string]any{"create": map[string]string{"_index": e.Index}})
+	if err != nil {
+		return &PermanentError{err}
+	}
+	var body bytes.Buffer
+	for _, line := range batch {
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.URL, "/")+"/_bulk", &body)
+	if err != nil {
+		return &PermanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+e.APIKey)
+	} else if e.Username != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+	resp, err := do(client(e.Client), req)
+	if err != nil {
+		return err
+	}
+
+	// 请求成功时仍可能有部分文档失败, 因 429 或 5xx 失败的文档重试, 其它失败的文档丢弃
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return &PermanentError{fmt.Errorf("elasticsearch: bad _bulk response: %w", err)}
+	}
+	if !result.Errors {
+		return nil
+	}
+	var retry [][]byte
+	rejected := 0
+	reason := ""
+	for i, item := range result.Items {
+		for _, r := range item {
+			switch {
+			case r.Status < 300:
+			case r.Status == http.StatusTooManyRequests || r.Status >= 500:
+				if i < len(batch) {
+					retry = append(retry, batch[i])
+				}
+			default:
+				rejected++
+				if reason == "" {
+					reason = r.Error.Type + ": " + r.Error.Reason
+				}
+			}
+		}
+	}
+	if len(retry) == 0 {
+		return &PermanentError{fmt.Errorf("elasticsearch: %d of %d documents rejected, %s", rejected, len(batch), reason)}
+	}
+	if len(retry) == len(batch) {
+		// 全部需要重试时整批保留在队列中, 不改变顺序
+		return fmt.Errorf("elasticsearch: %d documents failed with a temporary error", len(retry))
+	}
+	err = fmt.Errorf("elasticsearch: %d of %d documents to retry", len(retry), len(batch))
+	if rejected > 0 {
+		err = fmt.Errorf("elasticsearch: %d of %d documents to retry, %d rejected, %s", len(retry), len(batch), rejected, reason)
+	}
+	return &PartialError{Err: err, Retry: retry, Rejected: rejected}
+}
+
+// InsecureClient 返回不校验服务器证书的 http.Client
+func InsecureClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &http.Client{Transport: transport}
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// do 发送请求并返回响应内容
+// 408、429 和 5xx 可以重试, 其它非 2xx 的状态码返回 PermanentError
+func do(c *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return body, nil
+	}
+
+	err = fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(body[:min(len(body), 200)]))
+	if resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, err
+	}
+	return nil, &PermanentError{err}
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bulkServer 按 statuses 依次返回每个文档的状态
+func bulkServer(t *testing.T, statuses ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		items := make([]string, len(statuses))
+		for i, s := range statuses {
+			items[i] = `{"create":{"status":` + s + `,"error":{"type":"t","reason":"r"}}}`
+		}
+		io.WriteString(w, `{"errors":true,"items":[`+strings.Join(items, ",")+`]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestElasticsearchPartialRetry(t *testing.T) {
+	srv := bulkServer(t, "201", "429", "400", "503")
+	es := &Elasticsearch{URL: srv.URL, Index: "test"}
+	batch := [][]byte{[]byte(`{"n":0}`), []byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}
+
+	err := es.Send(context.Background(), batch)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Send returned %v, want *PartialError", err)
+	}
+	if len(partial.Retry) != 2 || string(partial.Retry[0]) != `{"n":1}` || string(partial.Retry[1]) != `{"n":3}` {
+		t.Errorf("Retry = %q, want the 429 and 503 documents", partial.Retry)
+	}
+	if partial.Rejected != 1 {
+		t.Errorf("Rejected = %d, want 1", partial.Rejected)
+	}
+}
+
+func TestElasticsearchAllRejected(t *testing.T) {
+	srv := bulkServer(t, "201", "400")
+	es := &Elasticsearch{URL: srv.URL, Index: "test"}
+	err := es.Send(context.Background(), [][]byte{[]byte(`{}`), []byte(`{}`)})
+	var perm *PermanentError
+	if !errors.As(err, &perm) {
+		t.Fatalf("Send returned %v, want *PermanentError", err)
+	}
+}
+
+func TestElasticsearchAllThrottled(t *testing.T) {
+	srv := bulkServer(t, "429", "429")
+	es := &Elasticsearch{URL: srv.URL, Index: "test"}
+	err := es.Send(context.Background(), [][]byte{[]byte(`{}`), []byte(`{}`)})
+	var perm *PermanentError
+	var partial *PartialError
+	if err == nil || errors.As(err, &perm) || errors.As(err, &partial) {
+		t.Fatalf("Send returned %v, want a plain retryable error", err)
+	}
+}
+
+// partialSender 第一次要求重试第二个事件, 之后全部成功
+type partialSender struct {
+	batches [][]string
+}
+
+func (s *partialSender) Send(_ context.Context, batch [][]byte) error {
+	var lines []string
+	for _, line := range batch {
+		lines = append(lines, string(line))
+	}
+	s.batches = append(s.batches, lines)
+	if len(s.batches) == 1 {
+		return &PartialError{Err: errors.New("retry"), Retry: batch[1:2], Rejected: 1}
+	}
+	return nil
+}
+
+func TestBatcherRequeuesPartialRetry(t *testing.T) {
+	sender := &partialSender{}
+	b := &Batcher{sender: sender, opts: BatchOptions{BatchSize: 10, Timeout: time.Second}, logger: discardLogger(), queue: &memQueue{max: 10}}
+	for _, line := range []string{"a", "b", "c"} {
+		b.queue.push([]byte(line))
+	}
+
+	var partial *PartialError
+	if err := b.send(); !errors.As(err, &partial) {
+		t.Fatalf("first send returned %v, want *PartialError", err)
+	}
+	if n := b.queue.len(); n != 1 {
+		t.Fatalf("%d events queued after a partial failure, want 1", n)
+	}
+	if err := b.send(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.batches) != 2 || strings.Join(sender.batches[1], ",") != "b" {
+		t.Errorf("batches sent: %q, want the retried event alone in the second", sender.batches)
+	}
+	if b.queue.len() != 0 {
+		t.Errorf("queue not empty: %d", b.queue.len())
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
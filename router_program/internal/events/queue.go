@@ -0,0 +1,241 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// queue 保存待发送的事件, peek 返回的 end 传给 commit 以移除这些事件
+type queue interface {
+	push(line []byte) (bool, error)
+	peek(n int) (lines [][]byte, end int64, err error)
+	commit(end int64) error
+	len() int
+	close() error
+}
+
+// memQueue 在内存中保存事件, 退出时丢失
+type memQueue struct {
+	mu    sync.Mutex
+	max   int
+	lines [][]byte
+}
+
+func (q *memQueue) push(line []byte) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.lines) >= q.max {
+		return false, nil
+	}
+	q.lines = append(q.lines, line)
+	return true, nil
+}
+
+func (q *memQueue) peek(n int) ([][]byte, int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n = min(n, len(q.lines))
+	return append([][]byte(nil), q.lines[:n]...), int64(n), nil
+}
+
+func (q *memQueue) commit(end int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lines = q.lines[end:]
+	if len(q.lines) == 0 {
+		q.lines = nil
+	}
+	return nil
+}
+
+func (q *memQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines)
+}
+
+func (q *memQueue) close() error {
+	return nil
+}
+
+// compactOffset 是压缩磁盘队列文件的阈值, 已发送的部分超过该大小时只保留未发送的部分
+const compactOffset = 1 << 20
+
+// diskQueue 把事件追加到 dir/queue.ndjson, 已发送的位置记录在 dir/offset 中
+// 全部发送后清空文件, 已发送的部分超过 compactOffset 时压缩文件
+type diskQueue struct {
+	mu      sync.Mutex
+	dir     string
+	max     int
+	f       *os.File
+	size    int64 // 文件大小
+	offset  int64 // 已发送的位置
+	pending int   // 未发送的事件数
+}
+
+func openDiskQueue(dir string, max int) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "queue.ndjson"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	q := &diskQueue{dir: dir, max: max, f: f}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "offset")); err == nil {
+		q.offset, _ = strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+	}
+	// 统计未发送的事件, 忽略写入一半的最后一行
+	r := bufio.NewReader(io.NewSectionReader(f, 0, 1<<62))
+	var pos int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		pos += int64(len(line))
+		if pos > q.offset {
+			q.pending++
+		}
+	}
+	q.size = pos
+	if q.offset > q.size {
+		q.offset = 0
+	}
+	if err := f.Truncate(q.size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *diskQueue) push(line []byte) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return false, errors.New("events: queue closed")
+	}
+	if q.pending >= q.max {
+		return false, nil
+	}
+	n, err := q.f.WriteAt(append(line, '\n'), q.size)
+	q.size += int64(n)
+	if err != nil {
+		return false, err
+	}
+	q.pending++
+	return true, nil
+}
+
+func (q *diskQueue) peek(n int) ([][]byte, int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return nil, q.offset, errors.New("events: queue closed")
+	}
+	r := bufio.NewReader(io.NewSectionReader(q.f, q.offset, q.size-q.offset))
+	var lines [][]byte
+	end := q.offset
+	for len(lines) < n {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		end += int64(len(line))
+		lines = append(lines, bytes.TrimSuffix(line, []byte{'\n'}))
+	}
+	return lines, end, nil
+}
+
+func (q *diskQueue) commit(end int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := bufio.NewReader(io.NewSectionReader(q.f, q.offset, end-q.offset))
+	for {
+		if _, err := r.ReadBytes('\n'); err != nil {
+			break
+		}
+		q.pending--
+	}
+	q.offset = end
+	if q.offset == q.size {
+		if err := q.f.Truncate(0); err != nil {
+			return err
+		}
+		q.offset, q.size = 0, 0
+	} else if q.offset >= compactOffset {
+		return q.compact()
+	}
+	return q.writeOffset()
+}
+
+// compact 把未发送的部分复制到新文件并替换原文件
+// 先把 offset 清零再改名, 中途退出时最多重复发送已发送的事件, 不会丢失
+func (q *diskQueue) compact() error {
+	path := filepath.Join(q.dir, "queue.ndjson")
+	f, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(f, io.NewSectionReader(q.f, q.offset, q.size-q.offset))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	offset := q.offset
+	q.offset = 0
+	if err := q.writeOffset(); err != nil {
+		q.offset = offset
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		q.offset = offset
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Join(err, q.writeOffset())
+	}
+	q.f.Close()
+	q.f, q.size = f, size
+	return nil
+}
+
+// writeOffset 先写临时文件再改名, 避免写入一半时退出
+func (q *diskQueue) writeOffset() error {
+	path := filepath.Join(q.dir, "offset")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(q.offset, 10)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (q *diskQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+func (q *diskQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f == nil {
+		return nil
+	}
+	err := q.f.Close()
+	q.f = nil
+	return err
+}
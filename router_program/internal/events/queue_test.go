@@ -0,0 +1,100 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func queueLine(i int) []byte {
+	return []byte(fmt.Sprintf(`{"n":%d,"pad":"%s"}`, i, bytes.Repeat([]byte{'x'}, 64<<10)))
+}
+
+func TestDiskQueueCompact(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 20
+	for i := 0; i < total; i++ {
+		if ok, err := q.push(queueLine(i)); !ok || err != nil {
+			t.Fatalf("push %d: %v %v", i, ok, err)
+		}
+	}
+
+	lines, end, err := q.peek(17)
+	if err != nil || len(lines) != 17 {
+		t.Fatalf("peek: %d lines, %v", len(lines), err)
+	}
+	if end < compactOffset {
+		t.Fatalf("17 lines end at %d, below the compaction threshold", end)
+	}
+	if err := q.commit(end); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "queue.ndjson")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(3 * (len(queueLine(17)) + 1)); fi.Size() != want {
+		t.Errorf("queue file is %d bytes after compaction, want %d", fi.Size(), want)
+	}
+	if offset, _ := os.ReadFile(filepath.Join(dir, "offset")); string(offset) != "0" {
+		t.Errorf("offset file contains %q, want 0", offset)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// 压缩后追加的事件和剩余的事件保持顺序, 重新打开后仍然存在
+	if ok, err := q.push(queueLine(total)); !ok || err != nil {
+		t.Fatalf("push after compaction: %v %v", ok, err)
+	}
+	if err := q.close(); err != nil {
+		t.Fatal(err)
+	}
+	q, err = openDiskQueue(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	if q.len() != 4 {
+		t.Errorf("reopened queue has %d events, want 4", q.len())
+	}
+	lines, _, err = q.peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range lines {
+		if want := queueLine(17 + i); !bytes.Equal(line, want) {
+			t.Errorf("event %d after compaction is %.20s..., want %.20s...", i, line, want)
+		}
+	}
+}
+
+func TestDiskQueueCommitBelowThreshold(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	for _, line := range []string{`{"n":1}`, `{"n":2}`} {
+		q.push([]byte(line))
+	}
+	_, end, _ := q.peek(1)
+	if err := q.commit(end); err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := os.ReadFile(filepath.Join(dir, "offset")); string(offset) != fmt.Sprint(end) {
+		t.Errorf("offset file contains %q, want %d", offset, end)
+	}
+	if lines, _, _ := q.peek(10); len(lines) != 1 || string(lines[0]) != `{"n":2}` {
+		t.Errorf("remaining events: %q", lines)
+	}
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxKeys 是每项统计最多记录的不同取值, 超过后计入 Other
+const maxKeys = 10000
+
+// Other 是统计中超出 maxKeys 的取值
+const Other = "(other)"
+
+// Count 是统计中的一项
+type Count struct {
+	Key     string    `json:"key"`
+	Count   int       `json:"count"`
+	Success int       `json:"success,omitempty"` // 只用于用户名, 登录成功的次数
+	Last    time.Time `json:"last"`
+}
+
+// Stats 是 Store 从启动以来的统计
+type Stats struct {
+	Started time.Time    `json:"started"`
+	Types   map[Type]int `json:"types"`
+	Clients []Count      `json:"clients"`  // 来源地址, 按次数降序
+	Users   []Count      `json:"users"`    // 尝试登录的用户名
+	Paths   []Count      `json:"paths"`    // 通过 mproxy 打开的路径
+	Exploit []Count      `json:"exploits"` // 检测到的漏洞利用, Key 为漏洞编号
+}
+
+// Store 是一个 Sink, 在内存中保存最近的事件和启动以来的统计, 供管理界面查询
+type Store struct {
+	mu      sync.Mutex
+	size    int
+	events  []Event // 环形缓冲区
+	next    int
+	started time.Time
+	types   map[Type]int
+	clients counter
+	users   counter
+	paths   counter
+	exploit counter
+}
+
+// NewStore 创建最多保存 size 条事件的 Store
+func NewStore(size int) *Store {
+	return &Store{
+		size:    size,
+		started: time.Now(),
+		types:   make(map[Type]int),
+		clients: make(counter),
+		users:   make(counter),
+		paths:   make(counter),
+		exploit: make(counter),
+	}
+}
+
+func (s *Store) Emit(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) < s.size {
+		s.events = append(s.events, *e)
+	} else {
+		s.events[s.next] = *e
+		s.next = (s.next + 1) % s.size
+	}
+
+	s.types[e.Type]++
+	switch e.Type {
+	case ConnectionOpen, ConnectionRejected:
+		s.clients.add(e.Client, e.Time)
+	case LoginAttempt:
+		s.users.add(e.User, e.Time)
+	case LoginSuccess:
+		s.users.success(e.User)
+	case FileOpen:
+		s.paths.add(e.Path, e.Time)
+	case ExploitDetected:
+		s.exploit.add(e.Reason, e.Time)
+	}
+	return nil
+}
+
+// Close 不清除已保存的事件, 重新设置事件输出后继续使用同一个 Store
+func (s *Store) Close() error {
+	return nil
+}
+
+// Recent 返回最近的 n 条事件, 最新的在前
+func (s *Store) Recent(n int) []Event {
+	return s.filter(n, func(*Event) bool { return true })
+}
+
+// ForConn 返回连接 conn 的事件, 按时间顺序
+func (s *Store) ForConn(conn uint64) []Event {
+	list := s.filter(s.size, func(e *Event) bool { return e.Conn == conn })
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+// filter 从最新的事件开始返回最多 n 条符合 match 的事件
+func (s *Store) filter(n int, match func(*Event) bool) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Event
+	for i := 0; i < len(s.events) && len(list) < n; i++ {
+		e := &s.events[(s.next-1-i+2*len(s.events))%len(s.events)]
+		if match(e) {
+			list = append(list, *e)
+		}
+	}
+	return list
+}
+
+// Stats 返回统计, 每项最多 top 个
+func (s *Store) Stats(top int) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make(map[Type]int, len(s.types))
+	for t, n := range s.types {
+		types[t] = n
+	}
+	return Stats{
+		Started: s.started,
+		Types:   types,
+		Clients: s.clients.top(top),
+		Users:   s.users.top(top),
+		Paths:   s.paths.top(top),
+		Exploit: s.exploit.top(top),
+	}
+}
+
+type counter map[string]*Count
+
+func (c counter) add(key string, t time.Time) {
+	n, ok := c[key]
+	if !ok {
+		if len(c) >= maxKeys {
+			key = Other
+		}
+		if n, ok = c[key]; !ok {
+			n = &Count{Key: key}
+			c[key] = n
+		}
+	}
+	n.Count++
+	n.Last = t
+}
+
+func (c counter) success(key string) {
+	if n, ok := c[key]; ok {
+		n.Success++
+	}
+}
+
+func (c counter) top(n int) []Count {
+	list := make([]Count, 0, len(c))
+	for _, v := range c {
+		list = append(list, *v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
@@ -1,27 +1,240 @@
 package log
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var Slog *slog.Logger
+// Options 是日志的输出位置、格式、级别和切割方式, 对应配置文件中的 log
+type Options struct {
+	File       string `json:"file"`       // 日志文件, stdout 和 stderr 表示标准输出和标准错误
+	Format     string `json:"format"`     // json 或 text
+	Level      string `json:"level"`      // debug、info、warn 或 error
+	MaxSize    int    `json:"maxSize"`    // 文件最大大小, 单位 MB
+	MaxAge     int    `json:"maxAge"`     // 最大保留天数, 0 表示不按时间删除
+	MaxBackups int    `json:"maxBackups"` // 最大保留文件数, 0 表示不按数量删除
+	Compress   bool   `json:"compress"`   // 是否用 gzip 压缩切割后的文件
 
+	Syslog SyslogOptions `json:"syslog"` // 同时发送到远程 syslog
+}
+
+// Defaults 是配置文件中没有 log 时使用的值
+var Defaults = Options{
+	File:       "./run.log",
+	Format:     "json",
+	Level:      "info",
+	MaxSize:    1,
+	MaxAge:     1,
+	MaxBackups: 3,
+}
+
+// Check 返回取值错误
+func (o Options) Check() []string {
+	var problems []string
+	if o.File == "" {
+		problems = append(problems, "file must not be empty, use stdout or stderr to log to the terminal")
+	}
+	if o.Format != "json" && o.Format != "text" {
+		problems = append(problems, fmt.Sprintf("format %q must be json or text", o.Format))
+	}
+	if _, err := parseLevel(o.Level); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if o.MaxSize < 0 || o.MaxAge < 0 || o.MaxBackups < 0 {
+		problems = append(problems, "maxSize, maxAge and maxBackups must not be negative")
+	}
+	return append(problems, o.Syslog.check()...)
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, fmt.Errorf("level %q must be debug, info, warn or error", s)
+	}
+	return l, nil
+}
+
+// Slog 在 Configure 之前输出到 slog.Default(), 便于作为库嵌入时使用
+// Configure 替换输出之后, 已经创建的 Slog 和由它派生的 logger 都使用新的输出
+var Slog = slog.New(&handler{})
+
+var (
+	level   = new(slog.LevelVar)
+	current atomic.Pointer[slog.Handler]
+
+	mu      sync.Mutex // 串行化 Configure
+	applied Options
+	rotator *lumberjack.Logger
+	output  slog.Handler // 文件或标准输出
+	syslogW *syslogWriter
+)
+
+func init() {
+	h := slog.Default().Handler()
+	current.Store(&h)
+}
+
+// InitLog 使用默认设置输出日志
 func InitLog() {
-	r := &lumberjack.Logger{
-		Filename:   "./run.log", //
-		MaxSize:    1,           // 文件最大大小 1M
-		MaxAge:     1,           // 最大保留时间 1天
-		MaxBackups: 3,           // 最大保留文件数 3个
-		LocalTime:  true,        // 是否用本机时间
-		Compress:   false,       // 是否压缩归档日志
+	Configure(Defaults)
+}
+
+// Configure 按 o 设置日志, 可以在运行时重复调用
+// 只修改级别时不会重新打开日志文件, syslog 设置不变时不会重新连接
+func Configure(o Options) error {
+	if problems := o.Check(); len(problems) > 0 {
+		return fmt.Errorf("log: %s", strings.Join(problems, "; "))
+	}
+	l, _ := parseLevel(o.Level)
+
+	mu.Lock()
+	defer mu.Unlock()
+	level.Set(l)
+	prev := applied
+	prev.Level = o.Level
+	if prev == o {
+		applied = o
+		return nil
+	}
+
+	var sw *syslogWriter
+	if o.Syslog != prev.Syslog && o.Syslog.Address != "" {
+		var err error
+		if sw, err = newSyslogWriter(o.Syslog); err != nil {
+			return fmt.Errorf("log: %w", err)
+		}
+	}
+	// 原来的 syslog 连接在新的 handler 生效之后再关闭, 期间的日志不会写入已关闭的连接
+	var oldSyslog *syslogWriter
+	if o.Syslog != prev.Syslog {
+		oldSyslog = syslogW
+		syslogW = sw
+	}
+	defer func() {
+		if oldSyslog != nil {
+			oldSyslog.Close(time.Second)
+		}
+	}()
+	prev.Syslog = o.Syslog
+	applied = o
+	if prev == o {
+		setOutput()
+		return nil
+	}
+
+	var w io.Writer
+	var r *lumberjack.Logger
+	switch o.File {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		r = &lumberjack.Logger{
+			Filename:   o.File,
+			MaxSize:    o.MaxSize,
+			MaxAge:     o.MaxAge,
+			MaxBackups: o.MaxBackups,
+			LocalTime:  true, // 是否用本机时间
+			Compress:   o.Compress,
+		}
+		w = r
 	}
 
 	opts := &slog.HandlerOptions{
 		//AddSource: true,
-		Level: slog.LevelInfo,
+		Level: level,
 	}
-	//Slog = slog.New(slog.NewJSONHandler(os.Stdout, opts))
-	Slog = slog.New(slog.NewJSONHandler(r, opts))
+	if o.Format == "text" {
+		output = slog.NewTextHandler(w, opts)
+	} else {
+		output = slog.NewJSONHandler(w, opts)
+	}
+	setOutput()
+
+	if rotator != nil {
+		rotator.Close()
+	}
+	rotator = r
+	return nil
+}
+
+// setOutput 替换当前生效的 handler, 调用者持有 mu
+func setOutput() {
+	h := output
+	if syslogW != nil {
+		h = fanout{output, &syslogHandler{w: syslogW, level: level}}
+	}
+	current.Store(&h)
+}
+
+// Close 关闭日志文件并尽量发送完 syslog 缓存, 在进程退出前调用
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if w := syslogW; w != nil {
+		syslogW = nil
+		setOutput()
+		w.Close(2 * time.Second)
+	}
+	if rotator == nil {
+		return nil
+	}
+	return rotator.Close()
+}
+
+// handler 把日志转发给当前生效的 handler
+// WithAttrs 和 WithGroup 记录在 ops 中, 输出改变后重新应用到新的 handler 上
+type handler struct {
+	ops   []func(slog.Handler) slog.Handler
+	cache atomic.Pointer[derived]
+}
+
+type derived struct {
+	base *slog.Handler
+	h    slog.Handler
+}
+
+func (h *handler) handler() slog.Handler {
+	base := current.Load()
+	if d := h.cache.Load(); d != nil && d.base == base {
+		return d.h
+	}
+	derivedHandler := *base
+	for _, op := range h.ops {
+		derivedHandler = op(derivedHandler)
+	}
+	h.cache.Store(&derived{base: base, h: derivedHandler})
+	return derivedHandler
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.handler().Enabled(ctx, l)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{ops: append(ops, op)}
 }
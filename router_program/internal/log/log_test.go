@@ -0,0 +1,169 @@
+package log
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// restore 在测试结束时关闭日志并恢复到 Configure 之前的状态
+func restore(t *testing.T) {
+	t.Cleanup(func() {
+		Close()
+		mu.Lock()
+		defer mu.Unlock()
+		applied, rotator, output, syslogW = Options{}, nil, nil, nil
+		h := slog.Default().Handler()
+		current.Store(&h)
+		level.Set(slog.LevelInfo)
+	})
+}
+
+func fileOptions(path, format string) Options {
+	o := Defaults
+	o.File, o.Format = path, format
+	return o
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Configure 之前创建的 logger 也使用新的输出
+func TestConfigureSwitchesOutput(t *testing.T) {
+	restore(t)
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	logger := Slog.With("session", 7).WithGroup("req")
+
+	if err := Configure(fileOptions(a, "json")); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("first", "id", 1)
+	if err := Configure(fileOptions(b, "text")); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("second", "id", 2)
+	logger.Debug("hidden")
+
+	// 只修改级别时继续写入同一个文件
+	debug := fileOptions(b, "text")
+	debug.Level = "debug"
+	if err := Configure(debug); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("shown")
+	Close()
+
+	if got := readLog(t, a); !strings.Contains(got, `"msg":"first","session":7,"req":{"id":1}`) || strings.Contains(got, "second") {
+		t.Errorf("a.log is %q", got)
+	}
+	got := readLog(t, b)
+	if !strings.Contains(got, "msg=second session=7 req.id=2") || strings.Contains(got, "first") {
+		t.Errorf("b.log is %q", got)
+	}
+	if strings.Contains(got, "hidden") || !strings.Contains(got, "msg=shown") {
+		t.Errorf("level change not applied: %q", got)
+	}
+}
+
+func TestConfigureRejectsBadOptions(t *testing.T) {
+	restore(t)
+	path := filepath.Join(t.TempDir(), "run.log")
+	if err := Configure(fileOptions(path, "json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(fileOptions(path, "xml")); err == nil {
+		t.Fatal("Configure accepted format xml")
+	}
+	// 出错时保持原来的输出
+	Slog.Info("kept")
+	Close()
+	if got := readLog(t, path); !strings.Contains(got, `"msg":"kept"`) {
+		t.Errorf("run.log is %q", got)
+	}
+}
+
+// 重新加载时其它 goroutine 持续写日志, 切换之后不再写入原来的 syslog
+func TestConfigureSwapsSyslogWhileLogging(t *testing.T) {
+	restore(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, _, err := pc.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "run.log")
+	withSyslog := fileOptions(path, "json")
+	withSyslog.Syslog = SyslogOptions{Address: pc.LocalAddr().String()}
+	if err := Configure(withSyslog); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	old := syslogW
+	mu.Unlock()
+	if old == nil {
+		t.Fatal("no syslog writer after Configure")
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger := Slog.With("worker", i)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					logger.Info("working")
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 5; i++ {
+		if err := Configure(fileOptions(path, "json")); err != nil {
+			t.Fatal(err)
+		}
+		if err := Configure(withSyslog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Configure(fileOptions(path, "json")); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+
+	mu.Lock()
+	if syslogW != nil {
+		t.Error("syslog writer still set after removing syslog")
+	}
+	mu.Unlock()
+	queued := len(old.queue)
+	for i := 0; i < 100; i++ {
+		Slog.Info("after")
+	}
+	if n := len(old.queue); n != queued {
+		t.Errorf("%d records were queued on the closed syslog writer", n-queued)
+	}
+}
@@ -0,0 +1,439 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// SyslogOptions 是发送到远程 syslog 的设置, 对应配置文件中的 log.syslog, address 为空时不发送
+type SyslogOptions struct {
+	Address            string `json:"address,omitempty"`            // host:port
+	Network            string `json:"network,omitempty"`            // udp(默认)、tcp 或 tls
+	Format             string `json:"format,omitempty"`             // rfc5424(默认) 或 rfc3164
+	Facility           string `json:"facility,omitempty"`           // 默认 local0
+	AppName            string `json:"appName,omitempty"`            // 默认为程序名
+	CAFile             string `json:"caFile,omitempty"`             // tls 时校验服务器证书的 CA, 为空时使用系统 CA
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // tls 时不校验服务器证书
+	BufferSize         int    `json:"bufferSize,omitempty"`         // 连接断开时缓存的日志条数, 默认 1024
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// sessionAttrs 是会话日志的属性, RFC 5424 中放在 session 结构化数据元素中, 其它属性放在 fields 中
+var sessionAttrs = map[string]bool{
+	"conn": true, "client": true, "port": true, "listener": true, "persona": true, "user": true, "filtered": true,
+}
+
+// sdID 使用 RFC 5612 中保留给文档示例的企业编号
+const sdID = "@32473"
+
+func (o SyslogOptions) check() []string {
+	if o.Address == "" {
+		return nil
+	}
+	var problems []string
+	if _, _, err := net.SplitHostPort(o.Address); err != nil {
+		problems = append(problems, fmt.Sprintf("syslog address %q must be host:port", o.Address))
+	}
+	switch o.Network {
+	case "", "udp", "tcp", "tls":
+	default:
+		problems = append(problems, fmt.Sprintf("syslog network %q must be udp, tcp or tls", o.Network))
+	}
+	if (o.CAFile != "" || o.InsecureSkipVerify) && o.Network != "tls" {
+		problems = append(problems, "syslog caFile and insecureSkipVerify are only used with network tls")
+	}
+	switch o.Format {
+	case "", "rfc5424", "rfc3164":
+	default:
+		problems = append(problems, fmt.Sprintf("syslog format %q must be rfc5424 or rfc3164", o.Format))
+	}
+	if _, ok := facilities[o.Facility]; o.Facility != "" && !ok {
+		problems = append(problems, fmt.Sprintf("syslog facility %q is not a syslog facility name such as daemon or local0", o.Facility))
+	}
+	if o.BufferSize < 0 {
+		problems = append(problems, "syslog bufferSize must not be negative")
+	}
+	return problems
+}
+
+// syslogWriter 在后台发送日志, 连接断开时缓存并不断重连
+type syslogWriter struct {
+	opts   SyslogOptions
+	tls    *tls.Config
+	queue  chan []byte
+	stop   chan struct{}
+	done   chan struct{}
+	header func(t time.Time, severity int) []byte
+
+	mu      sync.Mutex
+	dropped int
+}
+
+func newSyslogWriter(o SyslogOptions) (*syslogWriter, error) {
+	if o.Network == "" {
+		o.Network = "udp"
+	}
+	if o.Format == "" {
+		o.Format = "rfc5424"
+	}
+	if o.Facility == "" {
+		o.Facility = "local0"
+	}
+	if o.AppName == "" {
+		o.AppName = filepath.Base(os.Args[0])
+	}
+	if o.BufferSize == 0 {
+		o.BufferSize = 1024
+	}
+
+	w := &syslogWriter{
+		opts:  o,
+		queue: make(chan []byte, o.BufferSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if o.Network == "tls" {
+		host, _, _ := net.SplitHostPort(o.Address)
+		w.tls = &tls.Config{ServerName: host, InsecureSkipVerify: o.InsecureSkipVerify}
+		if o.CAFile != "" {
+			pem, err := os.ReadFile(o.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			w.tls.RootCAs = x509.NewCertPool()
+			if !w.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("syslog caFile %s: no certificates found", o.CAFile)
+			}
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	facility := facilities[o.Facility]
+	pid := strconv.Itoa(os.Getpid())
+	if o.Format == "rfc3164" {
+		w.header = func(t time.Time, severity int) []byte {
+			return fmt.Appendf(nil, "<%d>%s %s %s[%s]: ", facility*8+severity, t.Format(time.Stamp), hostname, o.AppName, pid)
+		}
+	} else {
+		// MSGID 和 STRUCTURED-DATA 都为空(-)
+		w.header = func(t time.Time, severity int) []byte {
+			return fmt.Appendf(nil, "<%d>1 %s %s %s %s - - ", facility*8+severity, t.Format("2006-01-02T15:04:05.000000Z07:00"), hostname, o.AppName, pid)
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// enqueue 不阻塞, 缓存满时丢弃并计数
+func (w *syslogWriter) enqueue(msg []byte) {
+	select {
+	case w.queue <- msg:
+	default:
+		w.mu.Lock()
+		w.dropped++
+		w.mu.Unlock()
+	}
+}
+
+func (w *syslogWriter) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	if w.tls != nil {
+		return tls.DialWithDialer(d, "tcp", w.opts.Address, w.tls)
+	}
+	return d.Dial(w.opts.Network, w.opts.Address)
+}
+
+// frame 按传输方式分帧: UDP 每条一个报文, TCP 和 TLS 使用 RFC 6587 的长度前缀(rfc3164 使用换行)
+func (w *syslogWriter) frame(msg []byte) []byte {
+	switch {
+	case w.opts.Network == "udp":
+		return msg
+	case w.opts.Format == "rfc3164":
+		return append(msg, '\n')
+	default:
+		return fmt.Appendf(nil, "%d %s", len(msg), msg)
+	}
+}
+
+func (w *syslogWriter) run() {
+	defer close(w.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	var pending []byte
+	delay := time.Second
+	for {
+		if pending == nil {
+			select {
+			case pending = <-w.queue:
+			case <-w.stop:
+				return
+			}
+		}
+
+		if conn == nil {
+			c, err := w.dial()
+			if err != nil {
+				select {
+				case <-time.After(delay):
+				case <-w.stop:
+					return
+				}
+				if delay *= 2; delay > 30*time.Second {
+					delay = 30 * time.Second
+				}
+				continue
+			}
+			conn, delay = c, time.Second
+			w.reportDropped(conn)
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(w.frame(pending)); err != nil {
+			conn.Close()
+			conn = nil
+			continue
+		}
+		pending = nil
+	}
+}
+
+// reportDropped 重连后发送一条日志说明断开期间丢弃的条数
+func (w *syslogWriter) reportDropped(conn net.Conn) {
+	w.mu.Lock()
+	n := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	msg := append(w.header(time.Now(), 4), fmt.Sprintf("syslog buffer full, %d messages dropped", n)...)
+	conn.Write(w.frame(msg))
+}
+
+// Close 尽量发送缓存中的日志, 最多等待 timeout
+func (w *syslogWriter) Close(timeout time.Duration) {
+	deadline := time.After(timeout)
+	for len(w.queue) > 0 {
+		select {
+		case <-deadline:
+			close(w.stop)
+			<-w.done
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	close(w.stop)
+	<-w.done
+}
+
+// syslogHandler 把日志格式化为 syslog 消息
+type syslogHandler struct {
+	w      *syslogWriter
+	level  slog.Leveler
+	attrs  []slog.Attr // 已经加上分组前缀
+	prefix string      // WithGroup 的分组, 以 . 结尾
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = flatten(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+func (h *syslogHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, flatten(nil, h.prefix, a)...)
+		return true
+	})
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := h.w.header(t, severity(r.Level))
+	if h.w.opts.Format == "rfc3164" {
+		msg = append(msg, r.Message...)
+		for _, a := range attrs {
+			msg = fmt.Appendf(msg, " %s=%s", a.Key, strconv.Quote(a.Value.String()))
+		}
+	} else {
+		// 替换 header 末尾表示没有结构化数据的 "- "
+		msg = msg[:len(msg)-2]
+		var session, fields []slog.Attr
+		for _, a := range attrs {
+			if sessionAttrs[a.Key] {
+				session = append(session, a)
+			} else {
+				fields = append(fields, a)
+			}
+		}
+		sd := appendSD(nil, "session", session)
+		sd = appendSD(sd, "fields", fields)
+		if len(sd) == 0 {
+			sd = append(sd, '-')
+		}
+		msg = append(append(msg, sd...), ' ')
+		msg = append(msg, r.Message...)
+	}
+	h.w.enqueue(msg)
+	return nil
+}
+
+// flatten 展开分组, 键名使用 . 连接
+func flatten(out []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return out
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, sub := range a.Value.Group() {
+			out = flatten(out, prefix, sub)
+		}
+		return out
+	}
+	a.Key = prefix + a.Key
+	return append(out, a)
+}
+
+// appendSD 写入一个 RFC 5424 结构化数据元素
+func appendSD(b []byte, name string, attrs []slog.Attr) []byte {
+	if len(attrs) == 0 {
+		return b
+	}
+	b = append(b, '[')
+	b = append(b, name+sdID...)
+	for _, a := range attrs {
+		b = append(b, ' ')
+		b = append(b, sdName(a.Key)...)
+		b = append(b, '=', '"')
+		b = append(b, sdEscape(a.Value.String())...)
+		b = append(b, '"')
+	}
+	return append(b, ']')
+}
+
+// sdName 替换参数名中不允许的字符, 最长 32 个字符
+func sdName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return string(name)
+}
+
+// sdEscape 转义参数值中的 " \ ], 并替换无效的 UTF-8
+func sdEscape(s string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	var b bytes.Buffer
+	for _, r := range s {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func severity(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// fanout 把日志同时交给多个 handler
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle 不再检查级别: 各个 handler 使用相同的级别, 会话的调试日志需要绕过级别输出
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		errs = append(errs, h.Handle(ctx, r.Clone()))
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithAttrs(attrs)
+	}
+	return f2
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithGroup(name)
+	}
+	return f2
+}
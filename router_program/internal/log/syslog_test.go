@@ -0,0 +1,237 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWriter 创建发送到 addr 的 syslogWriter 和使用它的 logger
+func newTestWriter(t *testing.T, o SyslogOptions) (*syslogWriter, *slog.Logger) {
+	t.Helper()
+	if o.AppName == "" {
+		o.AppName = "winbox"
+	}
+	w, err := newSyslogWriter(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close(time.Second) })
+	return w, slog.New(&syslogHandler{w: w, level: slog.LevelInfo})
+}
+
+func listenUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// readPacket 读取一个 UDP 报文
+func readPacket(t *testing.T, pc net.PacketConn) string {
+	t.Helper()
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64*1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+// acceptTCP 接受一个连接
+func acceptTCP(t *testing.T, l net.Listener) *bufio.Reader {
+	t.Helper()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+	select {
+	case conn, ok := <-accepted:
+		if !ok {
+			t.Fatal("accept failed")
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		return bufio.NewReader(conn)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the syslog writer did not connect")
+		return nil
+	}
+}
+
+// readOctetCounted 按 RFC 6587 读取 "长度 消息"
+func readOctetCounted(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	prefix, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+	if err != nil {
+		t.Fatalf("bad length prefix %q", prefix)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	return string(msg)
+}
+
+func TestSyslogRFC5424(t *testing.T) {
+	pc := listenUDP(t)
+	_, logger := newTestWriter(t, SyslogOptions{Address: pc.LocalAddr().String(), Facility: "daemon"})
+
+	logger.With("conn", 3, "client", "10.0.0.1").WithGroup("req").Warn("login failed", "user", "admin", "reason", `bad "pw"]`)
+	got := readPacket(t, pc)
+
+	// daemon(3)*8 + warning(4) = 28, MSGID 为 -
+	pattern := `^<28>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) \S+ winbox ` + strconv.Itoa(os.Getpid()) + ` - ` +
+		regexp.QuoteMeta(`[session@32473 conn="3" client="10.0.0.1"][fields@32473 req.user="admin" req.reason="bad \"pw\"\]"] login failed`) + `$`
+	if !regexp.MustCompile(pattern).MatchString(got) {
+		t.Errorf("got %q", got)
+	}
+
+	// 没有属性时结构化数据为 -
+	logger.Error("stopped")
+	if got := readPacket(t, pc); !strings.HasPrefix(got, "<27>1 ") || !strings.HasSuffix(got, " winbox "+strconv.Itoa(os.Getpid())+" - - stopped") {
+		t.Errorf("got %q", got)
+	}
+
+	// 低于级别的日志不发送
+	logger.Debug("hidden")
+	logger.Info("shown")
+	if got := readPacket(t, pc); !strings.HasSuffix(got, " shown") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSyslogRFC3164(t *testing.T) {
+	pc := listenUDP(t)
+	_, logger := newTestWriter(t, SyslogOptions{Address: pc.LocalAddr().String(), Format: "rfc3164"})
+
+	logger.Info("session end", "conn", 3, "client", "10.0.0.1")
+	got := readPacket(t, pc)
+
+	// local0(16)*8 + info(6) = 134
+	pattern := `^<134>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d \S+ winbox\[` + strconv.Itoa(os.Getpid()) + `\]: ` +
+		regexp.QuoteMeta(`session end conn="3" client="10.0.0.1"`) + `$`
+	if !regexp.MustCompile(pattern).MatchString(got) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// rfc5424 使用长度前缀, 消息中可以有换行
+	_, logger := newTestWriter(t, SyslogOptions{Address: l.Addr().String(), Network: "tcp"})
+	logger.Info("first\nline")
+	logger.Info("second")
+	r := acceptTCP(t, l)
+	for _, want := range []string{" - - first\nline", " - - second"} {
+		if got := readOctetCounted(t, r); !strings.HasPrefix(got, "<134>1 ") || !strings.HasSuffix(got, want) {
+			t.Errorf("got %q, want a message ending with %q", got, want)
+		}
+	}
+
+	// rfc3164 每行一条
+	_, logger = newTestWriter(t, SyslogOptions{Address: l.Addr().String(), Network: "tcp", Format: "rfc3164"})
+	logger.Info("third")
+	r = acceptTCP(t, l)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "<134>") || !strings.HasSuffix(line, ": third\n") {
+		t.Errorf("got %q", line)
+	}
+}
+
+// 连接不上时缓存 bufferSize 条, 其余丢弃, 重连后先报告丢弃的条数
+func TestSyslogBufferAndReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	const sent, buffer = 10, 4
+	w, logger := newTestWriter(t, SyslogOptions{Address: addr, Network: "tcp", BufferSize: buffer})
+	for i := 0; i < sent; i++ {
+		logger.Info("message", "n", i)
+	}
+	w.mu.Lock()
+	dropped := w.dropped
+	w.mu.Unlock()
+	// 最多 bufferSize 条在缓存中, 另有一条可能已经被取出等待发送
+	if dropped < sent-buffer-1 || dropped > sent-buffer {
+		t.Fatalf("dropped %d of %d messages with a buffer of %d", dropped, sent, buffer)
+	}
+
+	// 第一次连接失败后 1 秒重连
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("cannot listen on the same port again:", err)
+	}
+	defer l.Close()
+	r := acceptTCP(t, l)
+
+	report := readOctetCounted(t, r)
+	if !strings.HasPrefix(report, "<132>1 ") || !strings.HasSuffix(report, " - - syslog buffer full, "+strconv.Itoa(dropped)+" messages dropped") {
+		t.Errorf("first message after reconnecting is %q", report)
+	}
+	// 保留的是最早的几条
+	for i := 0; i < sent-dropped; i++ {
+		want := `[fields@32473 n="` + strconv.Itoa(i) + `"] message`
+		if got := readOctetCounted(t, r); !strings.HasSuffix(got, want) {
+			t.Errorf("message %d is %q, want it to end with %q", i, got, want)
+		}
+	}
+	w.mu.Lock()
+	if w.dropped != 0 {
+		t.Errorf("drop counter is %d after the report", w.dropped)
+	}
+	w.mu.Unlock()
+}
+
+func TestSyslogOptionsCheck(t *testing.T) {
+	for _, tt := range []struct {
+		o    SyslogOptions
+		want string
+	}{
+		{SyslogOptions{}, ""},
+		{SyslogOptions{Address: "logs:514"}, ""},
+		{SyslogOptions{Address: "logs"}, "must be host:port"},
+		{SyslogOptions{Address: "logs:514", Network: "quic"}, "must be udp, tcp or tls"},
+		{SyslogOptions{Address: "logs:514", CAFile: "ca.pem"}, "only used with network tls"},
+		{SyslogOptions{Address: "logs:514", Format: "json"}, "must be rfc5424 or rfc3164"},
+		{SyslogOptions{Address: "logs:514", Facility: "local9"}, "is not a syslog facility"},
+		{SyslogOptions{Address: "logs:514", BufferSize: -1}, "must not be negative"},
+	} {
+		problems := strings.Join(tt.o.check(), "; ")
+		if tt.want == "" && problems != "" || !strings.Contains(problems, tt.want) {
+			t.Errorf("%+v: problems %q, want %q", tt.o, problems, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// Metrics 是模拟器的全部指标
+type Metrics struct {
+	reg *Registry
+
+	ConnectionsActive *GaugeVec     // listener
+	ConnectionsTotal  *CounterVec   // listener
+	ConnectionsReject *CounterVec   // reason
+	Messages          *CounterVec   // sys_to, cmd
+	LoginAttempts     *CounterVec   // outcome
+	FileRequests      *CounterVec   // class
+	DecodeErrors      *CounterVec   // kind
+	BytesReceived     *CounterVec   // listener
+	BytesSent         *CounterVec   // listener
+	SessionDuration   *HistogramVec // listener
+	goroutines        *GaugeVec
+	startTime         *GaugeVec
+}
+
+// New 创建并注册全部指标
+func New() *Metrics {
+	r := &Registry{}
+	m := &Metrics{
+		reg:               r,
+		ConnectionsActive: r.NewGaugeVec("winbox_connections_active", "Connections currently being served.", "listener"),
+		ConnectionsTotal:  r.NewCounterVec("winbox_connections_total", "Connections accepted and served.", "listener"),
+		ConnectionsReject: r.NewCounterVec("winbox_connections_rejected_total", "Connections closed before being served, by reason.", "reason"),
+		Messages:          r.NewCounterVec("winbox_messages_total", "Requests received, by sys_to handler and command.", "sys_to", "cmd"),
+		LoginAttempts:     r.NewCounterVec("winbox_login_attempts_total", "Login requests, by outcome.", "outcome"),
+		FileRequests:      r.NewCounterVec("winbox_file_requests_total", "mproxy file open requests, by path class.", "class"),
+		DecodeErrors:      r.NewCounterVec("winbox_decode_errors_total", "Frames or messages that could not be handled, by kind.", "kind"),
+		BytesReceived:     r.NewCounterVec("winbox_received_bytes_total", "Bytes received from clients, counted when a session ends.", "listener"),
+		BytesSent:         r.NewCounterVec("winbox_sent_bytes_total", "Bytes sent to clients, counted when a session ends.", "listener"),
+		SessionDuration: r.NewHistogramVec("winbox_session_duration_seconds", "Session duration.",
+			[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}, "listener"),
+		goroutines: r.NewGaugeVec("go_goroutines", "Number of goroutines that currently exist."),
+		startTime:  r.NewGaugeVec("process_start_time_seconds", "Start time of the process since unix epoch in seconds."),
+	}
+	m.startTime.Set(float64(time.Now().Unix()))
+	return m
+}
+
+// Handler 返回输出指标的 http.Handler
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.goroutines.Set(float64(runtime.NumGoroutine()))
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.reg.Write(w)
+	})
+}
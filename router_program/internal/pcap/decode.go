@@ -0,0 +1,161 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	LinkTypeNull     = 0
+	LinkTypeLoop     = 108
+	LinkTypeLinuxSLL = 113
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+	LinkTypeSLL2     = 276
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+)
+
+// Segment 是解析出的一个 TCP 报文段
+type Segment struct {
+	Src     netip.AddrPort
+	Dst     netip.AddrPort
+	Seq     uint32
+	Flags   byte
+	Payload []byte
+}
+
+func (s Segment) SYN() bool { return s.Flags&tcpSyn != 0 }
+
+// Closed 表示报文段带有 FIN 或 RST
+func (s Segment) Closed() bool { return s.Flags&(tcpFin|tcpRst) != 0 }
+
+// DecodeTCP 从链路层报文中解析 TCP 报文段, 非 TCP 报文返回 false
+func DecodeTCP(linkType uint32, data []byte) (Segment, bool) {
+	var etherType uint16
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return Segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return Segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[14:])
+		data = data[16:]
+	case LinkTypeSLL2:
+		if len(data) < 20 {
+			return Segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[0:])
+		data = data[20:]
+	case LinkTypeNull, LinkTypeLoop:
+		if len(data) < 4 {
+			return Segment{}, false
+		}
+		data = data[4:]
+		etherType = ipVersion(data)
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		etherType = ipVersion(data)
+	default:
+		return Segment{}, false
+	}
+
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(data)
+	case etherTypeIPv6:
+		return decodeIPv6(data)
+	}
+	return Segment{}, false
+}
+
+func ipVersion(data []byte) uint16 {
+	if len(data) == 0 {
+		return 0
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return etherTypeIPv4
+	case 6:
+		return etherTypeIPv6
+	}
+	return 0
+}
+
+func decodeIPv4(data []byte) (Segment, bool) {
+	if len(data) < 20 {
+		return Segment{}, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(data[2:]))
+	fragment := binary.BigEndian.Uint16(data[6:])
+	// 不处理分片
+	if data[9] != 6 || fragment&0x3fff != 0 || headerLen < 20 || len(data) < headerLen {
+		return Segment{}, false
+	}
+	if totalLen >= headerLen && totalLen < len(data) {
+		data = data[:totalLen] // 去掉以太网填充
+	}
+	src := netip.AddrFrom4([4]byte(data[12:16]))
+	dst := netip.AddrFrom4([4]byte(data[16:20]))
+	return decodeTCP(src, dst, data[headerLen:])
+}
+
+func decodeIPv6(data []byte) (Segment, bool) {
+	if len(data) < 40 {
+		return Segment{}, false
+	}
+	payloadLen := int(binary.BigEndian.Uint16(data[4:]))
+	next := data[6]
+	src := netip.AddrFrom16([16]byte(data[8:24]))
+	dst := netip.AddrFrom16([16]byte(data[24:40]))
+	data = data[40:]
+	if payloadLen < len(data) {
+		data = data[:payloadLen]
+	}
+
+	// 跳过扩展头, 分片报文不处理
+	for next == 0 || next == 43 || next == 60 {
+		if len(data) < 8 {
+			return Segment{}, false
+		}
+		extLen := (int(data[1]) + 1) * 8
+		if len(data) < extLen {
+			return Segment{}, false
+		}
+		next = data[0]
+		data = data[extLen:]
+	}
+	if next != 6 {
+		return Segment{}, false
+	}
+	return decodeTCP(src, dst, data)
+}
+
+func decodeTCP(src, dst netip.Addr, data []byte) (Segment, bool) {
+	if len(data) < 20 {
+		return Segment{}, false
+	}
+	offset := int(data[12]>>4) * 4
+	if offset < 20 || len(data) < offset {
+		return Segment{}, false
+	}
+	return Segment{
+		Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(data[0:])),
+		Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(data[2:])),
+		Seq:     binary.BigEndian.Uint32(data[4:]),
+		Flags:   data[13],
+		Payload: data[offset:],
+	}, true
+}
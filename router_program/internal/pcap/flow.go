@@ -0,0 +1,181 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	tcpFin = 0x01
+	tcpSyn = 0x02
+	tcpRst = 0x04
+	tcpPsh = 0x08
+	tcpAck = 0x10
+
+	maxSegment = 1460
+)
+
+var (
+	clientMAC = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	serverMAC = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+// Flow 为一条 TCP 连接合成以太网/IP/TCP 报文头, 并维护双方的序列号
+type Flow struct {
+	client    netip.AddrPort
+	server    netip.AddrPort
+	clientSeq uint32
+	serverSeq uint32
+	ipID      uint16
+}
+
+// NewFlow 创建一条连接, 地址族不一致时统一使用 IPv6
+func NewFlow(client, server netip.AddrPort) *Flow {
+	c, s := client.Addr().Unmap(), server.Addr().Unmap()
+	if c.Is4() != s.Is4() {
+		c, s = netip.AddrFrom16(c.As16()), netip.AddrFrom16(s.As16())
+	}
+	return &Flow{
+		client:    netip.AddrPortFrom(c, client.Port()),
+		server:    netip.AddrPortFrom(s, server.Port()),
+		clientSeq: 1000,
+		serverSeq: 5000,
+	}
+}
+
+// Handshake 返回三次握手的三个报文
+func (f *Flow) Handshake() [][]byte {
+	frames := [][]byte{
+		f.segment(true, tcpSyn, nil),
+	}
+	f.clientSeq++
+	frames = append(frames, f.segment(false, tcpSyn|tcpAck, nil))
+	f.serverSeq++
+	frames = append(frames, f.segment(true, tcpAck, nil))
+	return frames
+}
+
+// Data 把一段负载按 MSS 切分为若干报文
+func (f *Flow) Data(fromClient bool, payload []byte) [][]byte {
+	var frames [][]byte
+	for len(payload) > 0 {
+		n := min(len(payload), maxSegment)
+		frames = append(frames, f.segment(fromClient, tcpPsh|tcpAck, payload[:n]))
+		if fromClient {
+			f.clientSeq += uint32(n)
+		} else {
+			f.serverSeq += uint32(n)
+		}
+		payload = payload[n:]
+	}
+	return frames
+}
+
+// Close 返回双方的 FIN 报文及最后的 ACK
+func (f *Flow) Close() [][]byte {
+	frames := [][]byte{
+		f.segment(false, tcpFin|tcpAck, nil),
+	}
+	f.serverSeq++
+	frames = append(frames, f.segment(true, tcpFin|tcpAck, nil))
+	f.clientSeq++
+	frames = append(frames, f.segment(false, tcpAck, nil))
+	return frames
+}
+
+func (f *Flow) segment(fromClient bool, flags byte, payload []byte) []byte {
+	src, dst := f.client, f.server
+	seq, ack := f.clientSeq, f.serverSeq
+	srcMAC, dstMAC := clientMAC, serverMAC
+	if !fromClient {
+		src, dst = dst, src
+		seq, ack = ack, seq
+		srcMAC, dstMAC = dstMAC, srcMAC
+	}
+	if flags&tcpAck == 0 {
+		ack = 0
+	}
+
+	tcp := make([]byte, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = 5 << 4 // data offset
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 0xffff) // window
+	copy(tcp[20:], payload)
+	binary.BigEndian.PutUint16(tcp[16:], tcpChecksum(src.Addr(), dst.Addr(), tcp))
+
+	var ip []byte
+	var etherType uint16
+	if src.Addr().Is4() {
+		etherType = 0x0800
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+		binary.BigEndian.PutUint16(ip[4:], f.ipID)
+		ip[6] = 0x40 // don't fragment
+		ip[8] = 64
+		ip[9] = 6
+		s, d := src.Addr().As4(), dst.Addr().As4()
+		copy(ip[12:], s[:])
+		copy(ip[16:], d[:])
+		binary.BigEndian.PutUint16(ip[10:], checksum(0, ip))
+		f.ipID++
+	} else {
+		etherType = 0x86dd
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		copy(ip[8:], s[:])
+		copy(ip[24:], d[:])
+	}
+
+	frame := make([]byte, 0, 14+len(ip)+len(tcp))
+	frame = append(frame, dstMAC...)
+	frame = append(frame, srcMAC...)
+	frame = binary.BigEndian.AppendUint16(frame, etherType)
+	frame = append(frame, ip...)
+	frame = append(frame, tcp...)
+	return frame
+}
+
+func tcpChecksum(src, dst netip.Addr, segment []byte) uint16 {
+	var pseudo []byte
+	if src.Is4() {
+		s, d := src.As4(), dst.As4()
+		pseudo = append(pseudo, s[:]...)
+		pseudo = append(pseudo, d[:]...)
+		pseudo = append(pseudo, 0, 6)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(segment)))
+	} else {
+		s, d := src.As16(), dst.As16()
+		pseudo = append(pseudo, s[:]...)
+		pseudo = append(pseudo, d[:]...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+		pseudo = append(pseudo, 0, 0, 0, 6)
+	}
+	return checksum(sum(0, pseudo), segment)
+}
+
+func sum(acc uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		acc += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		acc += uint32(data[len(data)-1]) << 8
+	}
+	return acc
+}
+
+func checksum(acc uint32, data []byte) uint16 {
+	acc = sum(acc, data)
+	for acc > 0xffff {
+		acc = (acc >> 16) + (acc & 0xffff)
+	}
+	return ^uint16(acc)
+}
@@ -0,0 +1,203 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"router/pkg/winbox"
+)
+
+// testdata/login.pcap 是一次真实会话的录制经 rec2pcap 转换的结果:
+// 客户端登录后通过 mproxy 下载插件清单, 服务端口为 18295
+const fixturePort = 18295
+
+func readPackets(t *testing.T, r io.Reader) []Packet {
+	t.Helper()
+	reader, err := NewReader(r)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var packets []Packet
+	for {
+		p, err := reader.ReadPacket()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("ReadPacket: %v", err)
+		}
+		packets = append(packets, p)
+	}
+}
+
+func fixture(t *testing.T) []Packet {
+	t.Helper()
+	f, err := os.Open("testdata/login.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return readPackets(t, f)
+}
+
+// frames 重组 packets 中的 TCP 数据, 返回客户端和服务端各自发送的报文
+func frames(t *testing.T, packets []Packet) (client, server []*winbox.Message) {
+	t.Helper()
+	var buf [2][]byte
+	assembler := NewAssembler(fixturePort, func(c Chunk) {
+		i := 1
+		if c.FromClient {
+			i = 0
+		}
+		buf[i] = append(buf[i], c.Data...)
+	})
+	for _, p := range packets {
+		if seg, ok := DecodeTCP(p.LinkType, p.Data); ok {
+			assembler.Add(p.Time, seg)
+		}
+	}
+	assembler.Flush()
+
+	var out [2][]*winbox.Message
+	for i := range buf {
+		data := buf[i]
+		for len(data) > 0 {
+			frame, n, err := winbox.SplitFrame(data)
+			if err != nil {
+				t.Fatalf("SplitFrame: %v", err)
+			}
+			out[i] = append(out[i], winbox.Decode(frame.Payload))
+			data = data[n:]
+		}
+	}
+	return out[0], out[1]
+}
+
+func checkSession(t *testing.T, client, server []*winbox.Message) {
+	t.Helper()
+	if len(client) != 4 || len(server) != 4 {
+		t.Fatalf("got %d client and %d server messages, want 4 and 4", len(client), len(server))
+	}
+	if !slices.Equal(client[0].GetU32Array(winbox.SysTo), []uint32{13, 4}) {
+		t.Errorf("first request is not sent to 13,4: %s", client[0].SerializeToJson())
+	}
+	if client[1].GetString(1) != "admin" {
+		t.Errorf("login request user = %q", client[1].GetString(1))
+	}
+	if client[2].GetString(1) != "list" {
+		t.Errorf("open request path = %q", client[2].GetString(1))
+	}
+	size := server[2].GetU32(2)
+	if size == 0 || len(server[3].GetRaw(3)) != int(size) {
+		t.Errorf("list is %d bytes, open reply says %d", len(server[3].GetRaw(3)), size)
+	}
+}
+
+func TestReadPcapFixture(t *testing.T) {
+	packets := fixture(t)
+	if len(packets) != 15 {
+		t.Fatalf("got %d packets, want 15", len(packets))
+	}
+	for i, p := range packets {
+		if p.LinkType != LinkTypeEthernet {
+			t.Fatalf("packet %d: link type %d", i, p.LinkType)
+		}
+		if i > 0 && p.Time.Before(packets[i-1].Time) {
+			t.Fatalf("packet %d: time goes backwards", i)
+		}
+	}
+	client, server := frames(t, packets)
+	checkSession(t, client, server)
+}
+
+func TestAssemblerOutOfOrder(t *testing.T) {
+	packets := fixture(t)
+	// 握手之后的报文倒序, 并重复其中一半, 模拟乱序和重传
+	reordered := slices.Clone(packets[:3])
+	rest := slices.Clone(packets[3:])
+	slices.Reverse(rest)
+	reordered = append(reordered, rest...)
+	reordered = append(reordered, rest[:len(rest)/2]...)
+
+	client, server := frames(t, reordered)
+	checkSession(t, client, server)
+}
+
+func TestReadPcapng(t *testing.T) {
+	packets := fixture(t)
+
+	// 用相同的报文生成纳秒精度的 pcapng
+	var b bytes.Buffer
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		total := uint32(12 + len(body))
+		binary.Write(&b, binary.LittleEndian, typ)
+		binary.Write(&b, binary.LittleEndian, total)
+		b.Write(body)
+		binary.Write(&b, binary.LittleEndian, total)
+	}
+	shb := binary.LittleEndian.AppendUint32(nil, byteOrderNg)
+	shb = binary.LittleEndian.AppendUint16(shb, 1)
+	shb = binary.LittleEndian.AppendUint16(shb, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	block(magicPcapng, shb)
+
+	idb := binary.LittleEndian.AppendUint16(nil, LinkTypeEthernet)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, 0)
+	idb = append(idb, optTsResol, 0, 1, 0, 9, 0, 0, 0) // if_tsresol = 10^-9
+	idb = append(idb, 0, 0, 0, 0)
+	block(blockIDB, idb)
+
+	for _, p := range packets {
+		ts := uint64(p.Time.UnixNano())
+		epb := binary.LittleEndian.AppendUint32(nil, 0)
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(len(p.Data)))
+		epb = binary.LittleEndian.AppendUint32(epb, uint32(len(p.Data)))
+		block(blockEPB, append(epb, p.Data...))
+	}
+
+	got := readPackets(t, &b)
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets, want %d", len(got), len(packets))
+	}
+	for i := range got {
+		if !got[i].Time.Equal(packets[i].Time) || !bytes.Equal(got[i].Data, packets[i].Data) {
+			t.Fatalf("packet %d differs", i)
+		}
+	}
+	client, server := frames(t, got)
+	checkSession(t, client, server)
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 123456000)
+	if err := w.WritePacket(ts, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	packets := readPackets(t, &b)
+	if len(packets) != 1 || !packets[0].Time.Equal(ts) || !bytes.Equal(packets[0].Data, []byte{1, 2, 3}) {
+		t.Fatalf("got %+v", packets)
+	}
+}
+
+func TestReaderUnknownFormat(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader(make([]byte, 24))); !errors.Is(err, ErrFormat) {
+		t.Fatalf("err = %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,247 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	magicPcapng   = 0x0a0d0d0a
+	byteOrderNg   = 0x1a2b3c4d
+	blockIDB      = 0x00000001
+	blockSPB      = 0x00000003
+	blockEPB      = 0x00000006
+	optEndOfOpt   = 0
+	optTsResol    = 9
+	maxBlockBytes = 64 << 20
+)
+
+var ErrFormat = errors.New("pcap: unknown file format")
+
+// Packet 是从抓包文件中读出的一个链路层报文
+type Packet struct {
+	Time     time.Time
+	LinkType uint32
+	Data     []byte
+}
+
+type iface struct {
+	linkType uint32
+	tsUnit   time.Duration // 每个时间戳单位的时长
+}
+
+// Reader 读取 pcap 或 pcapng 文件
+type Reader struct {
+	r      io.Reader
+	ng     bool
+	order  binary.ByteOrder
+	nano   bool
+	link   uint32
+	ifaces []iface
+}
+
+// NewReader 根据文件头自动识别 pcap/pcapng
+func NewReader(r io.Reader) (*Reader, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{r: r}
+	switch {
+	case binary.LittleEndian.Uint32(head) == magicPcapng:
+		reader.ng = true
+		header := append(head, make([]byte, 4)...)
+		if _, err := io.ReadFull(r, header[4:]); err != nil {
+			return nil, unexpected(err)
+		}
+		if err := reader.readSection(header); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	case binary.LittleEndian.Uint32(head) == magicMicroseconds:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(head) == magicMicroseconds:
+		reader.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(head) == magicNanoseconds:
+		reader.order, reader.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(head) == magicNanoseconds:
+		reader.order, reader.nano = binary.BigEndian, true
+	default:
+		return nil, ErrFormat
+	}
+
+	rest := make([]byte, 20)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, err
+	}
+	reader.link = reader.order.Uint32(rest[16:]) & 0x0fffffff
+	return reader, nil
+}
+
+// ReadPacket 返回下一个报文, 文件结束时返回 io.EOF
+func (r *Reader) ReadPacket() (Packet, error) {
+	if r.ng {
+		return r.readBlock()
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return Packet{}, err
+	}
+	sec := r.order.Uint32(header[0:])
+	frac := r.order.Uint32(header[4:])
+	capLen := r.order.Uint32(header[8:])
+	if capLen > maxBlockBytes {
+		return Packet{}, fmt.Errorf("pcap: packet length %d too large", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Packet{}, unexpected(err)
+	}
+
+	nsec := int64(frac) * 1000
+	if r.nano {
+		nsec = int64(frac)
+	}
+	return Packet{
+		Time:     time.Unix(int64(sec), nsec),
+		LinkType: r.link,
+		Data:     data,
+	}, nil
+}
+
+func (r *Reader) readBlock() (Packet, error) {
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r.r, header); err != nil {
+			return Packet{}, err
+		}
+		if binary.LittleEndian.Uint32(header) == magicPcapng {
+			if err := r.readSection(header); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+
+		blockType := r.order.Uint32(header[0:])
+		total := r.order.Uint32(header[4:])
+		if total < 12 || total > maxBlockBytes || total%4 != 0 {
+			return Packet{}, fmt.Errorf("pcapng: bad block length %d", total)
+		}
+		body := make([]byte, total-8)
+		if _, err := io.ReadFull(r.r, body); err != nil {
+			return Packet{}, unexpected(err)
+		}
+		body = body[:len(body)-4] // trailing block length
+
+		switch blockType {
+		case blockIDB:
+			r.addInterface(body)
+		case blockEPB:
+			if len(body) < 20 {
+				continue
+			}
+			id := r.order.Uint32(body[0:])
+			ts := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+			capLen := r.order.Uint32(body[12:])
+			if int(id) >= len(r.ifaces) || int(capLen) > len(body)-20 {
+				continue
+			}
+			ifc := r.ifaces[id]
+			return Packet{
+				Time:     timestamp(ts, ifc.tsUnit),
+				LinkType: ifc.linkType,
+				Data:     body[20 : 20+capLen],
+			}, nil
+		case blockSPB:
+			if len(body) < 4 || len(r.ifaces) == 0 {
+				continue
+			}
+			origLen := r.order.Uint32(body[0:])
+			data := body[4:]
+			if int(origLen) < len(data) {
+				data = data[:origLen]
+			}
+			return Packet{LinkType: r.ifaces[0].linkType, Data: data}, nil
+		}
+	}
+}
+
+// readSection 解析 Section Header Block, header 为已读取的块类型和长度
+func (r *Reader) readSection(header []byte) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, magic); err != nil {
+		return unexpected(err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(magic) == byteOrderNg:
+		r.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == byteOrderNg:
+		r.order = binary.BigEndian
+	default:
+		return ErrFormat
+	}
+	total := r.order.Uint32(header[4:])
+	if total < 12 || total > maxBlockBytes {
+		return ErrFormat
+	}
+	// 新的 section 会重新定义接口
+	r.ifaces = nil
+	_, err := io.CopyN(io.Discard, r.r, int64(total)-12)
+	return unexpected(err)
+}
+
+func (r *Reader) addInterface(body []byte) {
+	ifc := iface{tsUnit: time.Microsecond}
+	if len(body) >= 8 {
+		ifc.linkType = uint32(r.order.Uint16(body[0:]))
+		opts := body[8:]
+		for len(opts) >= 4 {
+			code := r.order.Uint16(opts[0:])
+			length := int(r.order.Uint16(opts[2:]))
+			if code == optEndOfOpt || 4+length > len(opts) {
+				break
+			}
+			if code == optTsResol && length >= 1 {
+				ifc.tsUnit = resolution(opts[4])
+			}
+			opts = opts[min(4+(length+3)&^3, len(opts)):]
+		}
+	}
+	r.ifaces = append(r.ifaces, ifc)
+}
+
+// resolution 解析 if_tsresol: 最高位为 0 表示 10 的负幂, 为 1 表示 2 的负幂
+func resolution(v byte) time.Duration {
+	exp := int(v & 0x7f)
+	if v&0x80 == 0 {
+		d := time.Second
+		for i := 0; i < exp && d > 1; i++ {
+			d /= 10
+		}
+		return d
+	}
+	if exp >= 30 {
+		return time.Nanosecond
+	}
+	return time.Second >> exp
+}
+
+func timestamp(ts uint64, unit time.Duration) time.Time {
+	perSec := uint64(time.Second / unit)
+	sec := ts / perSec
+	rem := ts % perSec
+	return time.Unix(int64(sec), int64(rem)*int64(unit))
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
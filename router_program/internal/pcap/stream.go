@@ -0,0 +1,160 @@
+package pcap
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// maxPending 限制每个方向缓存的乱序报文段数量
+const maxPending = 1024
+
+// Chunk 是按序重组后交付的一段数据
+type Chunk struct {
+	Time       time.Time
+	Client     netip.AddrPort
+	Server     netip.AddrPort
+	FromClient bool
+	Data       []byte
+}
+
+type streamKey struct {
+	client netip.AddrPort
+	server netip.AddrPort
+}
+
+type pendingSegment struct {
+	time time.Time
+	data []byte
+}
+
+// half 是 TCP 连接的一个方向
+type half struct {
+	next    uint32
+	synced  bool
+	pending map[uint32]pendingSegment
+}
+
+// Assembler 按服务端口识别连接方向, 重组两个方向上的 TCP 数据
+type Assembler struct {
+	port    uint16
+	streams map[streamKey]*[2]half
+	keys    []streamKey
+	deliver func(Chunk)
+}
+
+// NewAssembler 创建重组器, 目的端口为 port 的一侧视为客户端
+func NewAssembler(port uint16, deliver func(Chunk)) *Assembler {
+	return &Assembler{
+		port:    port,
+		streams: make(map[streamKey]*[2]half),
+		deliver: deliver,
+	}
+}
+
+// Add 处理一个报文段, 与服务端口无关的报文段被忽略
+func (a *Assembler) Add(ts time.Time, seg Segment) {
+	var key streamKey
+	var fromClient bool
+	switch {
+	case seg.Dst.Port() == a.port:
+		key, fromClient = streamKey{client: seg.Src, server: seg.Dst}, true
+	case seg.Src.Port() == a.port:
+		key, fromClient = streamKey{client: seg.Dst, server: seg.Src}, false
+	default:
+		return
+	}
+
+	halves, ok := a.streams[key]
+	if !ok {
+		halves = &[2]half{}
+		a.streams[key] = halves
+		a.keys = append(a.keys, key)
+	}
+	h := &halves[0]
+	if !fromClient {
+		h = &halves[1]
+	}
+
+	if seg.SYN() {
+		// 同一四元组上的新连接
+		*h = half{next: seg.Seq + 1, synced: true}
+		return
+	}
+	if len(seg.Payload) == 0 {
+		return
+	}
+	if !h.synced {
+		// 抓包开始时连接已经建立
+		*h = half{next: seg.Seq, synced: true}
+	}
+
+	emit := func(ts time.Time, data []byte) {
+		a.deliver(Chunk{Time: ts, Client: key.client, Server: key.server, FromClient: fromClient, Data: data})
+	}
+	h.insert(ts, seg.Seq, seg.Payload, emit)
+	h.drain(emit)
+}
+
+// Flush 在输入结束时交付所有剩余的乱序数据, 中间的缺口被跳过
+func (a *Assembler) Flush() {
+	for _, key := range a.keys {
+		halves := a.streams[key]
+		for i := range halves {
+			h := &halves[i]
+			fromClient := i == 0
+			emit := func(ts time.Time, data []byte) {
+				a.deliver(Chunk{Time: ts, Client: key.client, Server: key.server, FromClient: fromClient, Data: data})
+			}
+			for len(h.pending) > 0 {
+				seqs := make([]uint32, 0, len(h.pending))
+				for seq := range h.pending {
+					seqs = append(seqs, seq)
+				}
+				sort.Slice(seqs, func(i, j int) bool {
+					return int32(seqs[i]-h.next) < int32(seqs[j]-h.next)
+				})
+				h.next = seqs[0]
+				h.drain(emit)
+			}
+		}
+	}
+}
+
+func (h *half) insert(ts time.Time, seq uint32, data []byte, emit func(time.Time, []byte)) {
+	diff := int32(seq - h.next)
+	if int(diff)+len(data) <= 0 {
+		return // 重传
+	}
+	if diff <= 0 {
+		data = data[-diff:]
+		h.next += uint32(len(data))
+		emit(ts, data)
+		return
+	}
+	if h.pending == nil {
+		h.pending = make(map[uint32]pendingSegment)
+	}
+	if old, ok := h.pending[seq]; (!ok || len(old.data) < len(data)) && len(h.pending) < maxPending {
+		h.pending[seq] = pendingSegment{time: ts, data: data}
+	}
+}
+
+func (h *half) drain(emit func(time.Time, []byte)) {
+	for progressed := true; progressed; {
+		progressed = false
+		for seq, p := range h.pending {
+			diff := int32(seq - h.next)
+			if diff > 0 {
+				continue
+			}
+			delete(h.pending, seq)
+			if int(-diff) < len(p.data) {
+				data := p.data[-diff:]
+				h.next += uint32(len(data))
+				emit(p.time, data)
+			}
+			progressed = true
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+
+	snapLen = 0xffff
+)
+
+// Writer 输出 libpcap 格式(微秒精度, 以太网链路层)
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter 写入 pcap 全局头
+func NewWriter(w io.Writer) (*Writer, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], magicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:], 2) // version major
+	binary.LittleEndian.PutUint16(header[6:], 4) // version minor
+	binary.LittleEndian.PutUint32(header[16:], snapLen)
+	binary.LittleEndian.PutUint32(header[20:], LinkTypeEthernet)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// WritePacket 写入一个完整的以太网帧
+func (w *Writer) WritePacket(ts time.Time, frame []byte) error {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(header[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(frame)))
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.w.Write(frame)
+	return err
+}
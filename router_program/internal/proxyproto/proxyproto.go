@@ -0,0 +1,247 @@
+// Package proxyproto 解析 HAProxy PROXY 协议 v1/v2 头部, 用于获取负载均衡之后的真实客户端地址
+// 参考 https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNoHeader 表示可信来源的连接没有以 PROXY 头部开始
+	ErrNoHeader = errors.New("proxyproto: missing PROXY header")
+	// ErrInvalidHeader 表示头部格式错误
+	ErrInvalidHeader = errors.New("proxyproto: invalid PROXY header")
+)
+
+var signatureV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	maxV1Length = 107
+	v2HeaderLen = 16
+)
+
+// Header 是解析出的地址, Local 为 true 时(v1 UNKNOWN、v2 LOCAL 或非 TCP 地址族)
+// 没有携带地址, 应使用连接本身的地址
+type Header struct {
+	Version     int
+	Local       bool
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+}
+
+// ReadHeader 从 r 读取一个 v1 或 v2 头部
+func ReadHeader(r *bufio.Reader) (Header, error) {
+	// 连接关闭时 Peek 返回已有的部分数据和错误
+	sig, err := r.Peek(len(signatureV2))
+	switch {
+	case bytes.Equal(sig, signatureV2):
+		return readV2(r)
+	case bytes.HasPrefix(sig, []byte("PROXY ")):
+		return readV1(r)
+	case err != nil && !errors.Is(err, io.EOF):
+		return Header{}, err
+	}
+	return Header{}, ErrNoHeader
+}
+
+func readV1(r *bufio.Reader) (Header, error) {
+	var line []byte
+	for len(line) < maxV1Length {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Header{}, err
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			return parseV1(string(line[:len(line)-2]))
+		}
+	}
+	return Header{}, fmt.Errorf("%w: v1 header longer than %d bytes", ErrInvalidHeader, maxV1Length)
+}
+
+func parseV1(line string) (Header, error) {
+	h := Header{Version: 1}
+	fields := strings.Split(line, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		h.Local = true
+		return h, nil
+	}
+	if len(fields) != 6 || fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return h, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	src, err1 := netip.ParseAddr(fields[2])
+	dst, err2 := netip.ParseAddr(fields[3])
+	sport, err3 := strconv.ParseUint(fields[4], 10, 16)
+	dport, err4 := strconv.ParseUint(fields[5], 10, 16)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return h, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	if (fields[1] == "TCP4") != src.Is4() || src.Is4() != dst.Is4() {
+		return h, fmt.Errorf("%w: address family mismatch in %q", ErrInvalidHeader, line)
+	}
+	h.Source = netip.AddrPortFrom(src, uint16(sport))
+	h.Destination = netip.AddrPortFrom(dst, uint16(dport))
+	return h, nil
+}
+
+func readV2(r *bufio.Reader) (Header, error) {
+	h := Header{Version: 2}
+	head := make([]byte, v2HeaderLen)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return h, err
+	}
+	if head[12]>>4 != 2 {
+		return h, fmt.Errorf("%w: version %d", ErrInvalidHeader, head[12]>>4)
+	}
+	command, family := head[12]&0x0f, head[13]
+	body := make([]byte, binary.BigEndian.Uint16(head[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return h, err
+	}
+
+	switch {
+	case command == 0x0:
+		// LOCAL: 负载均衡自己的健康检查
+		h.Local = true
+	case command != 0x1:
+		return h, fmt.Errorf("%w: command %d", ErrInvalidHeader, command)
+	case family == 0x11 && len(body) >= 12:
+		src, _ := netip.AddrFromSlice(body[0:4])
+		dst, _ := netip.AddrFromSlice(body[4:8])
+		h.Source = netip.AddrPortFrom(src, binary.BigEndian.Uint16(body[8:]))
+		h.Destination = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(body[10:]))
+	case family == 0x21 && len(body) >= 36:
+		src, _ := netip.AddrFromSlice(body[0:16])
+		dst, _ := netip.AddrFromSlice(body[16:32])
+		h.Source = netip.AddrPortFrom(src, binary.BigEndian.Uint16(body[32:]))
+		h.Destination = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(body[34:]))
+	case family == 0x11 || family == 0x21:
+		return h, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	default:
+		// UDP、UNIX 和 UNSPEC 不携带 TCP 地址
+		h.Local = true
+	}
+	return h, nil
+}
+
+// Conn 在第一次读取或调用 Handshake 时解析 PROXY 头部
+// 之后 RemoteAddr 和 LocalAddr 返回头部中的地址, 在此之前返回连接本身(负载均衡)的地址,
+// 按来源地址做判断之前应先调用 Handshake
+type Conn struct {
+	net.Conn
+	trusted bool
+	timeout time.Duration
+
+	once   sync.Once
+	done   atomic.Bool // Handshake 已完成, 之后 err 和 header 不再改变
+	err    error
+	reader *bufio.Reader
+	header Header
+}
+
+// Handshake 读取 PROXY 头部, 来源不可信时不读取
+// 在头部读取完成前 RemoteAddr 返回连接本身的地址
+func (c *Conn) Handshake() error {
+	c.once.Do(func() {
+		defer c.done.Store(true)
+		if !c.trusted {
+			return
+		}
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.header, c.err = ReadHeader(c.reader)
+	})
+	return c.err
+}
+
+// Header 返回解析出的头部, 没有读取头部时 ok 为 false
+func (c *Conn) Header() (h Header, ok bool) {
+	if err := c.Handshake(); err != nil || !c.trusted {
+		return Header{}, false
+	}
+	return c.header, true
+}
+
+// Proxy 返回负载均衡(即连接本身)的地址
+func (c *Conn) Proxy() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+// NetConn 返回底层的连接
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.hasAddrs() {
+		return net.TCPAddrFromAddrPort(c.header.Source)
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	if c.hasAddrs() {
+		return net.TCPAddrFromAddrPort(c.header.Destination)
+	}
+	return c.Conn.LocalAddr()
+}
+
+// hasAddrs 判断头部中是否有可用的地址, Handshake 完成之前返回 false
+// 可能与 Handshake 在不同的 goroutine 中调用, 通过 done 保证读到完整的结果
+func (c *Conn) hasAddrs() bool {
+	return c.trusted && c.done.Load() && c.err == nil && !c.header.Local && c.header.Source.IsValid()
+}
+
+// Listener 接受的连接为 *Conn
+type Listener struct {
+	net.Listener
+	// Trusted 判断连接来源是否可信, 只有可信来源的 PROXY 头部会被使用, 且必须带有头部
+	// 不可信来源的连接原样传递; 为 nil 时不信任任何来源
+	Trusted func(addr netip.Addr) bool
+	// Timeout 为读取头部的超时时间, 为 0 时不设置
+	Timeout time.Duration
+}
+
+// NewListener 包装 l, trusted 的含义见 Listener.Trusted
+func NewListener(l net.Listener, trusted func(addr netip.Addr) bool) *Listener {
+	return &Listener{Listener: l, Trusted: trusted, Timeout: 5 * time.Second}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	trusted := false
+	if ap, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil && l.Trusted != nil {
+		trusted = l.Trusted(ap.Addr().Unmap())
+	}
+	return &Conn{
+		Conn:    conn,
+		trusted: trusted,
+		timeout: l.Timeout,
+		reader:  bufio.NewReader(conn),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listen 返回信任所有来源的 Listener 和连接到它的客户端
+func listen(t *testing.T) (*Listener, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := NewListener(l, func(netip.Addr) bool { return true })
+	pl.Timeout = time.Second
+	t.Cleanup(func() { pl.Close() })
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return pl, client
+}
+
+func TestRemoteAddrAfterHandshake(t *testing.T) {
+	pl, client := listen(t)
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pc := conn.(*Conn)
+
+	proxy := client.LocalAddr().String()
+	if got := pc.RemoteAddr().String(); got != proxy {
+		t.Errorf("RemoteAddr before the handshake = %s, want the proxy address %s", got, proxy)
+	}
+
+	// 握手期间其它 goroutine 读取地址不应产生数据竞争
+	var wg sync.WaitGroup
+	started, stop := make(chan struct{}), make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		close(started)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pc.RemoteAddr()
+				pc.LocalAddr()
+			}
+		}
+	}()
+	<-started
+	client.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.2 40000 8291\r\n"))
+	err = pc.Handshake()
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if got := pc.RemoteAddr().String(); got != "192.0.2.1:40000" {
+		t.Errorf("RemoteAddr = %s, want 192.0.2.1:40000", got)
+	}
+	if got := pc.LocalAddr().String(); got != "198.51.100.2:8291" {
+		t.Errorf("LocalAddr = %s, want 198.51.100.2:8291", got)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	pl, _ := listen(t)
+	pl.Timeout = 50 * time.Millisecond
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.(*Conn).Handshake(); err == nil {
+		t.Fatal("Handshake without a header succeeded")
+	}
+	if _, ok := conn.(*Conn).Header(); ok {
+		t.Error("Header returned ok after a failed handshake")
+	}
+}
@@ -0,0 +1,183 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeSession = "session"
+	TypeData    = "data"
+
+	DirIn  = "in"  // 客户端 -> 服务端
+	DirOut = "out" // 服务端 -> 客户端
+)
+
+// Entry 是录制文件中的一行(JSON Lines)
+// 第一行为 session 记录, 之后每次读写产生一条 data 记录
+type Entry struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Client   string    `json:"client,omitempty"`
+	Listener string    `json:"listener,omitempty"`
+	Dir      string    `json:"dir,omitempty"`
+	Data     []byte    `json:"data,omitempty"`
+}
+
+// Conn 包装 net.Conn, 把所有收发的数据写入录制文件
+type Conn struct {
+	net.Conn
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	path string
+}
+
+// Wrap 在 dir 目录下为 conn 创建一个新的录制文件
+func Wrap(conn net.Conn, dir string) (*Conn, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	name := fmt.Sprintf("%s_%s.jsonl", start.Format("20060102T150405.000000000"), sanitize(conn.RemoteAddr().String()))
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{
+		Conn: conn,
+		file: file,
+		buf:  bufio.NewWriter(file),
+		path: path,
+	}
+	c.enc = json.NewEncoder(c.buf)
+	err = c.enc.Encode(Entry{
+		Type:     TypeSession,
+		Time:     start,
+		Client:   conn.RemoteAddr().String(),
+		Listener: conn.LocalAddr().String(),
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Path 返回录制文件路径
+func (c *Conn) Path() string {
+	return c.path
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.write(DirIn, b[:n])
+	}
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.write(DirOut, b[:n])
+	}
+	return n, err
+}
+
+// Flush 把缓冲中的记录写入磁盘
+func (c *Conn) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	return c.buf.Flush()
+}
+
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != nil {
+		err = errors.Join(err, c.buf.Flush(), c.file.Close())
+		c.file = nil
+	}
+	return err
+}
+
+func (c *Conn) write(dir string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	// 录制失败不影响会话本身
+	c.enc.Encode(Entry{
+		Type: TypeData,
+		Time: time.Now(),
+		Dir:  dir,
+		Data: data,
+	})
+}
+
+// ReadFile 读取一个录制文件的全部记录
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read 从 r 中解析录制记录, 第一条必须是 session 记录
+// 录制进程在写入时被终止会留下不完整的最后一行, 这一行被忽略
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		text, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return entries, err
+		}
+		complete := err == nil
+		if len(bytes.TrimSpace(text)) > 0 {
+			var e Entry
+			if jsonErr := json.Unmarshal(text, &e); jsonErr != nil {
+				if !complete {
+					break
+				}
+				return entries, fmt.Errorf("record: line %d: %w", line, jsonErr)
+			}
+			entries = append(entries, e)
+		}
+		if !complete {
+			break
+		}
+	}
+
+	if len(entries) == 0 || entries[0].Type != TypeSession {
+		return nil, errors.New("record: missing session header")
+	}
+	return entries, nil
+}
+
+func sanitize(addr string) string {
+	return strings.NewReplacer(":", "_", "[", "", "]", "", "%", "_").Replace(addr)
+}
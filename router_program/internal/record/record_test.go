@@ -0,0 +1,48 @@
+package record
+
+import (
+	"strings"
+	"testing"
+)
+
+const header = `{"type":"session","time":"2024-01-02T03:04:05Z","client":"10.0.0.1:1234","listener":"10.0.0.2:8291"}`
+
+func TestReadTruncatedLastLine(t *testing.T) {
+	input := header + "\n" +
+		`{"type":"data","time":"2024-01-02T03:04:06Z","dir":"in","data":"AQI="}` + "\n" +
+		`{"type":"data","time":"2024-01-02T03:04:07Z","dir":"out","da`
+	entries, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[1].Dir != DirIn || string(entries[1].Data) != "\x01\x02" {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+}
+
+func TestReadLastLineWithoutNewline(t *testing.T) {
+	input := header + "\n" + `{"type":"data","time":"2024-01-02T03:04:06Z","dir":"out","data":"AQI="}`
+	entries, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+}
+
+func TestReadCorruptLine(t *testing.T) {
+	input := header + "\n" + "not json\n" + `{"type":"data","time":"2024-01-02T03:04:06Z","dir":"in"}` + "\n"
+	if _, err := Read(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Read error = %v, want an error for line 2", err)
+	}
+}
+
+func TestReadMissingHeader(t *testing.T) {
+	if _, err := Read(strings.NewReader(`{"type":"data","time":"2024-01-02T03:04:06Z"}` + "\n")); err == nil {
+		t.Fatal("Read accepted a file without a session header")
+	}
+}
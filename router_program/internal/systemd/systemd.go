@@ -0,0 +1,126 @@
+// Package systemd 实现 systemd 的 socket activation 和 sd_notify 协议, 不依赖 libsystemd
+// 参考 sd_listen_fds(3) 和 sd_notify(3)
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFdsStart 是 systemd 传递的第一个文件描述符
+const listenFdsStart = 3
+
+// Listener 是 systemd 传递的监听, Name 来自 .socket 文件中的 FileDescriptorName=
+type Listener struct {
+	net.Listener
+	Name string
+}
+
+// Listeners 返回 socket activation 传递的监听, 没有时返回空列表
+// 调用后清除 LISTEN_* 环境变量, 避免子进程重复使用
+func Listeners() ([]Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	var listeners []Listener
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		// FileListener 复制了文件描述符, 原来的可以关闭
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("systemd: fd %d (%s): %w", fd, name, err)
+		}
+		listeners = append(listeners, Listener{Listener: l, Name: name})
+	}
+	return listeners, nil
+}
+
+// Notify 向 NOTIFY_SOCKET 发送状态, 例如 "READY=1"
+// 不是由 systemd 以 Type=notify 启动时返回 false 和 nil
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// @ 开头的是 Linux 抽象命名空间中的地址
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval 返回 systemd 要求的看门狗间隔(WatchdogSec=), 没有启用时返回 0
+func WatchdogInterval() (time.Duration, error) {
+	s := os.Getenv("WATCHDOG_USEC")
+	if s == "" {
+		return 0, nil
+	}
+	if p := os.Getenv("WATCHDOG_PID"); p != "" {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("systemd: bad WATCHDOG_PID %q", p)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+	usec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, errors.New("systemd: bad WATCHDOG_USEC " + strconv.Quote(s))
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// Watchdog 按 WatchdogInterval 的一半发送 WATCHDOG=1, 直到 stop 被关闭
+func Watchdog(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			Notify("WATCHDOG=1")
+		}
+	}
+}
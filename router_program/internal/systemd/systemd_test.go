@@ -0,0 +1,115 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenersNotActivated(t *testing.T) {
+	for _, env := range []struct{ pid, fds string }{
+		{"", ""},
+		{"1", "1"}, // 传给了其它进程
+		{strconv.Itoa(os.Getpid()), ""},
+		{strconv.Itoa(os.Getpid()), "0"},
+		{strconv.Itoa(os.Getpid()), "x"},
+	} {
+		t.Setenv("LISTEN_PID", env.pid)
+		t.Setenv("LISTEN_FDS", env.fds)
+		t.Setenv("LISTEN_FDNAMES", "winbox")
+		listeners, err := Listeners()
+		if len(listeners) != 0 || err != nil {
+			t.Errorf("LISTEN_PID=%q LISTEN_FDS=%q: got %d listeners, %v", env.pid, env.fds, len(listeners), err)
+		}
+		for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+			if v, ok := os.LookupEnv(name); ok {
+				t.Errorf("%s=%q was not cleared", name, v)
+			}
+		}
+	}
+}
+
+// TestListeners 在子进程中把两个监听放在 fd 3 和 4 上, 由 sh 设置 LISTEN_PID 为子进程自己的 pid
+func TestListeners(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("needs sh to set LISTEN_PID")
+	}
+	var files []*os.File
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		f, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	exe, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(sh, "-c", `LISTEN_PID=$$ exec "$0" -test.run='^TestListenersChild$' -test.v`, exe)
+	cmd.Env = append(os.Environ(), "SYSTEMD_TEST_CHILD=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=winbox")
+	cmd.ExtraFiles = files
+	out, err := cmd.CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "--- PASS: TestListenersChild") {
+		t.Fatalf("child: %v\n%s", err, out)
+	}
+}
+
+func TestListenersChild(t *testing.T) {
+	if os.Getenv("SYSTEMD_TEST_CHILD") != "1" {
+		t.Skip("run by TestListeners")
+	}
+	listeners, err := Listeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("got %d listeners, want 2", len(listeners))
+	}
+	// 没有名字的使用 LISTEN_FD_<fd>
+	for i, want := range []string{"winbox", "LISTEN_FD_4"} {
+		l := listeners[i]
+		defer l.Close()
+		if l.Name != want {
+			t.Errorf("listener %d is named %q, want %q", i, l.Name, want)
+		}
+		if err := accept(l); err != nil {
+			t.Errorf("listener %s: %v", l.Name, err)
+		}
+	}
+	if v, ok := os.LookupEnv("LISTEN_PID"); ok {
+		t.Errorf("LISTEN_PID=%q was not cleared", v)
+	}
+}
+
+// accept 检查 l 可以接受连接
+func accept(l net.Listener) error {
+	conn, err := net.DialTimeout("tcp", l.Addr().String(), 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	accepted, err := l.Accept()
+	if err != nil {
+		return err
+	}
+	defer accepted.Close()
+	if accepted.RemoteAddr().String() != conn.LocalAddr().String() {
+		return fmt.Errorf("accepted %s, dialed from %s", accepted.RemoteAddr(), conn.LocalAddr())
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package client 实现 winbox 协议(8291 端口)的客户端, 用于测试和自动化
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"router/pkg/winbox"
+)
+
+// 常用的 sys_to 地址
+var (
+	ToLogin  = []uint32{13, 4}
+	ToMproxy = []uint32{2, 2}
+)
+
+// mproxy 文件命令
+const (
+	cmdLogin      = 1
+	cmdRead       = 4
+	cmdHash       = 4
+	cmdCancel     = 5
+	cmdOpenNoAuth = 7
+)
+
+const (
+	fieldUser     = 1
+	fieldFileName = 1
+	fieldFileSize = 2
+	fieldFileData = 3
+	fieldSalt     = 9
+	fieldHash     = 10
+)
+
+// ErrRequestID 表示回复中的请求 id 与发送的请求不一致
+var ErrRequestID = errors.New("winbox: reply does not match the request id")
+
+// Error 是服务端返回的错误消息
+type Error struct {
+	Code    uint32
+	Message string
+	Reply   *winbox.Message
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("winbox: %s (code 0x%x)", e.Message, e.Code)
+}
+
+// Client 是一条 winbox 连接, 同一时间只处理一个请求
+type Client struct {
+	// Timeout 为每个请求的读写超时, 为 0 表示不设置
+	Timeout time.Duration
+	// Handle 为发送报文时使用的 handle 字节
+	Handle byte
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *winbox.Reader
+	seq    uint32
+}
+
+// Dial 连接 addr(host:port)
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// New 在已建立的连接上创建客户端
+func New(conn net.Conn) *Client {
+	return &Client{
+		Timeout: 10 * time.Second,
+		Handle:  winbox.HandleDefault,
+		conn:    conn,
+		reader:  winbox.NewReader(conn),
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Send 发送一个消息, 不等待回复
+func (c *Client) Send(msg *winbox.Message) error {
+	frame, err := winbox.EncodeFrame(c.Handle, msg.Marshal())
+	if err != nil {
+		return err
+	}
+	c.deadline()
+	_, err = c.conn.Write(frame)
+	return err
+}
+
+// Receive 读取下一个消息
+func (c *Client) Receive() (*winbox.Message, error) {
+	c.deadline()
+	frame, err := c.reader.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return winbox.Decode(frame.Payload), nil
+}
+
+// Request 发送请求并等待回复, 自动填写 seq、请求 id 和 reply expected
+// 跳过之前超时的请求迟到的回复, 其它请求 id 不一致的回复返回 ErrRequestID
+// 回复中带有错误码或错误信息时返回 *Error
+func (c *Client) Request(msg *winbox.Message) (*winbox.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	msg.AddU32(winbox.Seq, c.seq)
+	msg.SetRequestID(c.seq)
+	msg.SetReplyExpected(true)
+	if len(msg.GetU32Array(winbox.From)) == 0 {
+		msg.AddU32Array(winbox.From, []uint32{0, 8})
+	}
+
+	if err := c.Send(msg); err != nil {
+		return nil, err
+	}
+	var reply *winbox.Message
+	for {
+		var err error
+		reply, err = c.Receive()
+		if err != nil {
+			return nil, err
+		}
+		id := reply.GetU32(winbox.RequestId)
+		if id == c.seq {
+			break
+		}
+		if id == 0 || id > c.seq {
+			return reply, fmt.Errorf("%w: got %d, want %d", ErrRequestID, id, c.seq)
+		}
+	}
+	if reply.HasError() {
+		return reply, &Error{
+			Code:    reply.GetU32(winbox.ErrorCode),
+			Message: reply.ErrorString(),
+			Reply:   reply,
+		}
+	}
+	return reply, nil
+}
+
+// SysTo 向 to 指定的处理器发送命令 cmd, fields 为附加字段, 可以为 nil
+func (c *Client) SysTo(to []uint32, cmd uint32, fields *winbox.Message) (*winbox.Message, error) {
+	msg := fields
+	if msg == nil {
+		msg = winbox.NewMessage()
+	}
+	msg.AddU32Array(winbox.SysTo, to)
+	msg.SetCommand(cmd)
+	return c.Request(msg)
+}
+
+// Login 使用 MD5 挑战应答登录, 成功时返回服务端的回复(包含设备信息)
+func (c *Client) Login(user, password string) (*winbox.Message, error) {
+	challenge, err := c.SysTo(ToLogin, cmdHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	salt := challenge.GetRaw(fieldSalt)
+	if len(salt) == 0 {
+		return nil, errors.New("winbox: no salt in login challenge")
+	}
+
+	login := winbox.NewMessage()
+	login.AddString(fieldUser, user)
+	login.AddRaw(fieldSalt, salt)
+	login.AddRaw(fieldHash, HashPassword(password, salt))
+	return c.SysTo(ToLogin, cmdLogin, login)
+}
+
+// HashPassword 计算登录请求中的口令散列: 0x00 + md5(0x00 + password + salt)
+func HashPassword(password, salt string) string {
+	hash := md5.New()
+	hash.Write([]byte{0})
+	hash.Write([]byte(password))
+	hash.Write([]byte(salt))
+	return string(append([]byte{0}, hash.Sum(nil)...))
+}
+
+// File 是通过 mproxy 打开的文件
+type File struct {
+	Name      string
+	Size      uint32
+	SessionID uint32
+}
+
+// OpenFile 通过 mproxy [2,2] 以免认证方式打开文件
+func (c *Client) OpenFile(name string) (*File, error) {
+	req := winbox.NewMessage()
+	req.AddString(fieldFileName, name)
+	reply, err := c.SysTo(ToMproxy, cmdOpenNoAuth, req)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Name:      name,
+		Size:      reply.GetU32(fieldFileSize),
+		SessionID: reply.GetSessionID(),
+	}, nil
+}
+
+// Read 读取已打开文件的下一块内容
+func (c *Client) Read(f *File) ([]byte, error) {
+	req := winbox.NewMessage()
+	req.SetSessionID(f.SessionID)
+	req.AddU32(fieldFileSize, f.Size)
+	reply, err := c.SysTo(ToMproxy, cmdRead, req)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(reply.GetRaw(fieldFileData)), nil
+}
+
+// CloseFile 取消对文件的读取
+func (c *Client) CloseFile(f *File) error {
+	req := winbox.NewMessage()
+	req.SetSessionID(f.SessionID)
+	_, err := c.SysTo(ToMproxy, cmdCancel, req)
+	return err
+}
+
+// ReadFile 打开并读取整个文件
+func (c *Client) ReadFile(name string) ([]byte, error) {
+	f, err := c.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for uint32(len(data)) < f.Size {
+		chunk, err := c.Read(f)
+		if err != nil {
+			return data, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		data = append(data, chunk...)
+	}
+	if uint32(len(data)) != f.Size {
+		return data, fmt.Errorf("winbox: read %d bytes of %s, expected %d", len(data), name, f.Size)
+	}
+	return data, nil
+}
+
+func (c *Client) deadline() {
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+}
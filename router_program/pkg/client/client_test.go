@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"router/internal/app"
+	"router/internal/config"
+	"router/pkg/winbox"
+)
+
+// startServer 在 127.0.0.1:0 上启动使用默认配置(admin/admin)的服务端
+func startServer(t *testing.T) (*app.Server, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m, err := config.NewManager("", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := app.NewServer(app.WithConfigManager(m), app.WithListener(l), app.WithLogger(logger))
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background()) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-done; !errors.Is(err, app.ErrServerClosed) {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+	return s, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Timeout = 5 * time.Second
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestLoginAndReadFile(t *testing.T) {
+	_, addr := startServer(t)
+	c := dial(t, addr)
+
+	reply, err := c.Login("admin", "admin")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if reply.GetSessionID() == 0 {
+		t.Errorf("login reply has no session id: %s", reply.SerializeToJson())
+	}
+
+	data, err := c.ReadFile("list")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(data, app.ListData) {
+		t.Errorf("ReadFile(list) returned %d bytes, want the built-in list of %d bytes", len(data), len(app.ListData))
+	}
+
+	if _, err := c.ReadFile("/flash/rw/store/missing"); err == nil {
+		t.Error("ReadFile of an unknown file succeeded")
+	}
+}
+
+func TestLoginBadPassword(t *testing.T) {
+	_, addr := startServer(t)
+	c := dial(t, addr)
+
+	_, err := c.Login("admin", "wrong")
+	var werr *Error
+	if !errors.As(err, &werr) {
+		t.Fatalf("Login with a bad password returned %v, want *Error", err)
+	}
+
+	// 同一连接上仍然可以用正确的口令登录
+	if _, err := c.Login("admin", "admin"); err != nil {
+		t.Fatalf("Login after a failure: %v", err)
+	}
+}
+
+// fakeServer 读取并丢弃请求, 依次发送 ids 中的请求 id 的回复后关闭连接
+// net.Pipe 的 Write 在对方读完后才返回, 关闭时回复已经全部被读取
+func fakeServer(t *testing.T, conn net.Conn, ids ...uint32) {
+	t.Helper()
+	go func() {
+		r := winbox.NewReader(conn)
+		for {
+			if _, err := r.ReadFrame(); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer conn.Close()
+		for _, id := range ids {
+			reply := winbox.NewMessage()
+			reply.SetRequestID(id)
+			frame, _ := winbox.EncodeFrame(winbox.HandleDefault, reply.Marshal())
+			if _, err := conn.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestRequestSkipsStaleReply(t *testing.T) {
+	local, remote := net.Pipe()
+	fakeServer(t, remote, 1, 2)
+	c := New(local)
+	defer c.Close()
+	c.seq = 1 // 请求 1 的回复被当作迟到的回复
+
+	reply, err := c.Request(winbox.NewMessage())
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if id := reply.GetU32(winbox.RequestId); id != 2 {
+		t.Errorf("got the reply for request %d, want 2", id)
+	}
+}
+
+func TestRequestIDMismatch(t *testing.T) {
+	local, remote := net.Pipe()
+	fakeServer(t, remote, 7)
+	c := New(local)
+	defer c.Close()
+
+	if _, err := c.Request(winbox.NewMessage()); !errors.Is(err, ErrRequestID) {
+		t.Fatalf("Request returned %v, want ErrRequestID", err)
+	}
+}
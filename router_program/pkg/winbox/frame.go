@@ -0,0 +1,154 @@
+package winbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 报文格式: |length|handle|m2 binary seq|
+// 超过 0xff 的消息会被切分, 后续分片为 |length|0xff|data|
+const (
+	HandleDefault byte = 0x01
+	handleChunk   byte = 0xff
+
+	maxChunk = 0xff
+	MaxSize  = 0xffff
+)
+
+var (
+	ErrIncomplete    = errors.New("winbox: incomplete frame")
+	ErrInvalidHeader = errors.New("winbox: invalid frame header")
+	ErrOversized     = errors.New("winbox: message oversized")
+)
+
+// Frame 是重组后的一个完整报文
+type Frame struct {
+	Handle  byte
+	Payload []byte
+}
+
+// Marshal 返回带 "M2" 标识的消息体
+func (w *Message) Marshal() []byte {
+	return append([]byte("M2"), w.SerializeToBinary()...)
+}
+
+// Decode 解析消息体, "M2" 标识可有可无
+func Decode(payload []byte) *Message {
+	msg := NewMessage()
+	msg.ParseBinary(payload)
+	return msg
+}
+
+// EncodeFrame 为消息体加上长度头, 并按需切分
+func EncodeFrame(handle byte, message []byte) ([]byte, error) {
+	if len(message) > MaxSize {
+		return nil, ErrOversized
+	}
+
+	msgSize := []byte{
+		byte(len(message) >> 8),   // 0: upper byte
+		byte(len(message) & 0xff), // 1: lower byte
+	}
+
+	var request bytes.Buffer
+
+	if len(message) < 0xfe {
+		request.WriteByte(byte(msgSize[1] + 2))
+		request.WriteByte(handle)
+		request.Write(msgSize)
+		request.Write(message)
+	} else {
+		request.WriteByte(maxChunk)
+		request.WriteByte(handle)
+		request.Write(msgSize)
+		request.Write(message[:0xfd]) // 0xff-2, because we write 2 bytes above
+		for i := 0xfd; i < len(message); i += maxChunk {
+			var remain byte
+			if len(message)-i > maxChunk {
+				remain = maxChunk
+			} else {
+				remain = byte(len(message) - i)
+			}
+			request.WriteByte(remain)
+			request.WriteByte(handleChunk)
+			request.Write(message[i : i+int(remain)])
+		}
+	}
+	return request.Bytes(), nil
+}
+
+// SplitFrame 从 data 开头取出一个完整报文, 返回报文和消耗的字节数
+// 数据不足时返回 ErrIncomplete; 长度头不一致时返回 ErrInvalidHeader,
+// 此时仍然返回该分片的长度以便调用方跳过
+func SplitFrame(data []byte) (Frame, int, error) {
+	if len(data) < 2 {
+		return Frame{}, 0, ErrIncomplete
+	}
+	shortLength := int(data[0])
+	handle := data[1]
+	if len(data) < 2+shortLength {
+		return Frame{}, 0, ErrIncomplete
+	}
+	if shortLength < 2 {
+		return Frame{}, 2 + shortLength, ErrInvalidHeader
+	}
+
+	longLength := int(binary.BigEndian.Uint16(data[2:4]))
+	if shortLength != maxChunk && shortLength-2 != longLength {
+		return Frame{}, 2 + shortLength, ErrInvalidHeader
+	}
+
+	payload := make([]byte, 0, longLength)
+	payload = append(payload, data[4:2+shortLength]...)
+	consumed := 2 + shortLength
+	for len(payload) < longLength {
+		if len(data) < consumed+2 {
+			return Frame{}, 0, ErrIncomplete
+		}
+		chunk := int(data[consumed])
+		if len(data) < consumed+2+chunk {
+			return Frame{}, 0, ErrIncomplete
+		}
+		payload = append(payload, data[consumed+2:consumed+2+chunk]...)
+		consumed += 2 + chunk
+	}
+	if len(payload) != longLength {
+		return Frame{}, consumed, ErrInvalidHeader
+	}
+	return Frame{Handle: handle, Payload: payload}, consumed, nil
+}
+
+// Reader 从字节流中读取并重组报文
+type Reader struct {
+	r   io.Reader
+	buf []byte
+	err error
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadFrame 读取下一个报文, 遇到 ErrInvalidHeader 时可以继续读取
+func (r *Reader) ReadFrame() (Frame, error) {
+	tmp := make([]byte, 4096)
+	for {
+		frame, n, err := SplitFrame(r.buf)
+		if err != ErrIncomplete {
+			r.buf = r.buf[n:]
+			return frame, err
+		}
+		if r.err != nil {
+			if r.err == io.EOF && len(r.buf) > 0 {
+				return Frame{}, io.ErrUnexpectedEOF
+			}
+			return Frame{}, r.err
+		}
+
+		n, err = r.r.Read(tmp)
+		r.buf = append(r.buf, tmp[:n]...)
+		r.err = err
+	}
+}
@@ -0,0 +1,113 @@
+package winbox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func payload(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestEncodeSplitFrameRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 0xfb, 0xfc, 0xfd, 0xfe, 0xff, 0x100, 0x1fa, 0x1fb, 0x1fc, 1000, MaxSize} {
+		msg := payload(n)
+		data, err := EncodeFrame(HandleDefault, msg)
+		if err != nil {
+			t.Fatalf("EncodeFrame(%d bytes): %v", n, err)
+		}
+		frame, consumed, err := SplitFrame(data)
+		if err != nil {
+			t.Fatalf("SplitFrame(%d bytes): %v", n, err)
+		}
+		if consumed != len(data) {
+			t.Errorf("%d bytes: consumed %d of %d", n, consumed, len(data))
+		}
+		if frame.Handle != HandleDefault || !bytes.Equal(frame.Payload, msg) {
+			t.Errorf("%d bytes: payload or handle changed", n)
+		}
+	}
+}
+
+func TestEncodeFrameChunks(t *testing.T) {
+	msg := payload(0x100)
+	data, err := EncodeFrame(HandleDefault, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一个分片: 0xff, handle, 长度 0x0100, 0xfd 字节数据
+	if data[0] != 0xff || data[1] != HandleDefault || data[2] != 0x01 || data[3] != 0x00 {
+		t.Fatalf("first chunk header = % x", data[:4])
+	}
+	// 第二个分片: 剩余 3 字节, handle 为 0xff
+	rest := data[2+0xff:]
+	if len(rest) != 2+3 || rest[0] != 3 || rest[1] != 0xff {
+		t.Fatalf("second chunk = % x", rest)
+	}
+
+	// 刚好填满分片时不产生空的分片
+	data, _ = EncodeFrame(HandleDefault, payload(0xfd+0xff))
+	if len(data) != 2+0xff+2+0xff {
+		t.Errorf("frame of 0x%x bytes is %d bytes long", 0xfd+0xff, len(data))
+	}
+}
+
+func TestEncodeFrameOversized(t *testing.T) {
+	if _, err := EncodeFrame(HandleDefault, payload(MaxSize+1)); !errors.Is(err, ErrOversized) {
+		t.Fatalf("err = %v, want ErrOversized", err)
+	}
+}
+
+func TestSplitFrameIncomplete(t *testing.T) {
+	data, _ := EncodeFrame(HandleDefault, payload(600))
+	for i := 0; i < len(data); i++ {
+		if _, n, err := SplitFrame(data[:i]); !errors.Is(err, ErrIncomplete) || n != 0 {
+			t.Fatalf("prefix of %d bytes: n=%d err=%v, want ErrIncomplete", i, n, err)
+		}
+	}
+}
+
+func TestSplitFrameInvalidHeader(t *testing.T) {
+	// 短长度与长长度不一致
+	data := []byte{5, HandleDefault, 0x00, 0x04, 'M', '2', 0}
+	_, n, err := SplitFrame(data)
+	if !errors.Is(err, ErrInvalidHeader) || n != 7 {
+		t.Fatalf("n=%d err=%v, want 7 and ErrInvalidHeader", n, err)
+	}
+}
+
+func TestReaderReassembles(t *testing.T) {
+	var stream []byte
+	sizes := []int{10, 0x1fb, 3000}
+	for _, n := range sizes {
+		data, _ := EncodeFrame(HandleDefault, payload(n))
+		stream = append(stream, data...)
+	}
+	r := NewReader(iotest.OneByteReader(bytes.NewReader(stream)))
+	for _, n := range sizes {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		if !bytes.Equal(frame.Payload, payload(n)) {
+			t.Fatalf("frame of %d bytes changed", n)
+		}
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+
+	r = NewReader(bytes.NewReader(stream[:len(stream)-1]))
+	r.ReadFrame()
+	r.ReadFrame()
+	if _, err := r.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated stream: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
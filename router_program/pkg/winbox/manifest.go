@@ -0,0 +1,193 @@
+package winbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ManifestEntry 是 list 文件中的一条插件记录, 例如
+// { crc: 1645628733, size: 1149, name: "advtool.jg", unique: "advtool-fc1932f6809e.jg", version: "6.41.4" },
+type ManifestEntry struct {
+	CRC     uint32 `json:"crc"`
+	Size    uint32 `json:"size"`
+	Name    string `json:"name"`
+	Unique  string `json:"unique,omitempty"`
+	Version string `json:"version"`
+}
+
+// Manifest 是 mproxy 返回给客户端的 list 文件
+type Manifest []ManifestEntry
+
+// ParseManifest 解析 list 文件格式
+func ParseManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	text := string(data)
+	line := 1
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			if strings.TrimSpace(strings.ReplaceAll(text, ",", "")) != "" {
+				return nil, fmt.Errorf("line %d: unexpected text outside a record", line)
+			}
+			return m, nil
+		}
+		if strings.TrimSpace(strings.ReplaceAll(text[:start], ",", "")) != "" {
+			return nil, fmt.Errorf("line %d: unexpected text outside a record", line)
+		}
+		line += strings.Count(text[:start], "\n")
+
+		end := closingBrace(text, start)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated record", line)
+		}
+		entry, err := parseManifestEntry(text[start+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		m = append(m, entry)
+
+		line += strings.Count(text[start:end], "\n")
+		text = text[end+1:]
+	}
+}
+
+// closingBrace 查找与 start 处 { 匹配的 }, 忽略引号中的内容
+func closingBrace(text string, start int) int {
+	quoted := false
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '}':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseManifestEntry(body string) (ManifestEntry, error) {
+	var e ManifestEntry
+	seen := make(map[string]bool)
+	for len(strings.TrimSpace(body)) > 0 {
+		colon := strings.IndexByte(body, ':')
+		if colon < 0 {
+			return e, fmt.Errorf("missing ':' in %q", strings.TrimSpace(body))
+		}
+		key := strings.TrimSpace(body[:colon])
+		body = strings.TrimLeft(body[colon+1:], " \t\r\n")
+
+		var value string
+		if strings.HasPrefix(body, `"`) {
+			end := closingQuote(body)
+			if end < 0 {
+				return e, fmt.Errorf("unterminated string for %s", key)
+			}
+			unquoted, err := strconv.Unquote(body[:end+1])
+			if err != nil {
+				return e, fmt.Errorf("bad string for %s: %w", key, err)
+			}
+			value = unquoted
+			body = body[end+1:]
+		} else {
+			end := strings.IndexByte(body, ',')
+			if end < 0 {
+				end = len(body)
+			}
+			value = strings.TrimSpace(body[:end])
+			body = body[end:]
+		}
+		body = strings.TrimLeft(body, " \t\r\n")
+		body = strings.TrimPrefix(body, ",")
+
+		if seen[key] {
+			return e, fmt.Errorf("duplicate key %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "crc", "size":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return e, fmt.Errorf("bad %s %q", key, value)
+			}
+			if key == "crc" {
+				e.CRC = uint32(n)
+			} else {
+				e.Size = uint32(n)
+			}
+		case "name":
+			e.Name = value
+		case "unique":
+			e.Unique = value
+		case "version":
+			e.Version = value
+		default:
+			return e, fmt.Errorf("unknown key %s", key)
+		}
+	}
+	if e.Name == "" {
+		return e, errors.New("record without name")
+	}
+	return e, nil
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Bytes 生成 list 文件, 格式与 RouterOS 返回的一致
+func (m Manifest) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, e := range m {
+		fmt.Fprintf(&buf, "{ crc: %d, size: %d, name: %s, ", e.CRC, e.Size, strconv.Quote(e.Name))
+		if e.Unique != "" {
+			fmt.Fprintf(&buf, "unique: %s, ", strconv.Quote(e.Unique))
+		}
+		fmt.Fprintf(&buf, "version: %s },\n", strconv.Quote(e.Version))
+	}
+	return buf.Bytes()
+}
+
+// Validate 检查 dir 目录下以 name 命名的文件的大小和 crc32 是否与记录一致
+func (m Manifest) Validate(dir string) []error {
+	var errs []error
+	for _, e := range m {
+		// name 来自 list 文件, 只能是 dir 下的文件名, 不能指向其它目录
+		if strings.ContainsAny(e.Name, `/\`) || strings.Contains(e.Name, "..") {
+			errs = append(errs, fmt.Errorf("%s: name is not a plain file name", e.Name))
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+			continue
+		}
+		if uint32(len(data)) != e.Size {
+			errs = append(errs, fmt.Errorf("%s: size is %d, manifest says %d", e.Name, len(data), e.Size))
+		}
+		if sum := crc32.ChecksumIEEE(data); sum != e.CRC {
+			errs = append(errs, fmt.Errorf("%s: crc is %d, manifest says %d", e.Name, sum, e.CRC))
+		}
+	}
+	return errs
+}
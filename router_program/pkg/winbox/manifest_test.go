@@ -0,0 +1,111 @@
+package winbox
+
+import (
+	"bytes"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 与内置 list 文件格式相同的几行
+const sampleList = `{ crc: 164562873, size: 1149, name: "advtool.jg", unique: "advtool-fc1932f6809e.jg", version: "6.41.4" },
+{ crc: 3670689488, size: 3119, name: "dhcp.jg", unique: "dhcp-75f66994ba41.jg", version: "6.41.4" },
+{ crc: 1183779834, size: 12489, name: "dude.jg", unique: "dude-65f18faed649.jg", version: "6.41.4" },
+{ crc: 444782794, size: 433, name: "gps.jg", version: "6.41.4" },
+`
+
+func TestManifestRoundTrip(t *testing.T) {
+	m, err := ParseManifest([]byte(sampleList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("parsed %d entries, want 4", len(m))
+	}
+	want := ManifestEntry{CRC: 3670689488, Size: 3119, Name: "dhcp.jg", Unique: "dhcp-75f66994ba41.jg", Version: "6.41.4"}
+	if m[1] != want {
+		t.Errorf("entry 1 is %+v, want %+v", m[1], want)
+	}
+	if m[3].Unique != "" {
+		t.Errorf("entry without unique parsed as %q", m[3].Unique)
+	}
+
+	if got := m.Bytes(); string(got) != sampleList {
+		t.Errorf("Bytes() changed the list:\n%s", got)
+	}
+	again, err := ParseManifest(m.Bytes())
+	if err != nil || !slices.Equal(again, m) {
+		t.Errorf("parsing Bytes() again gave %v, %v", again, err)
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	for _, text := range []string{
+		`{ crc: 1, size: 2, name: "a.jg", version: "1" }, junk`,
+		`{ crc: 1, size: 2, name: "a.jg", version: "1"`,
+		`{ crc: x, size: 2, name: "a.jg", version: "1" }`,
+		`{ crc: 1, size: 2, version: "1" }`,
+		`{ crc: 1, crc: 2, name: "a.jg" }`,
+		`{ crc: 1, name: "a.jg", color: "red" }`,
+		`{ name: "a.jg }`,
+	} {
+		if _, err := ParseManifest([]byte(text)); err == nil {
+			t.Errorf("ParseManifest(%q) succeeded", text)
+		}
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := []byte("good plugin")
+	if err := os.WriteFile(filepath.Join(dir, "good.jg"), good, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.jg"), []byte("good plugiN"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// dir 之外的文件, 校验值与记录一致也不能被读取
+	outside := filepath.Join(filepath.Dir(dir), "outside.jg")
+	if err := os.WriteFile(outside, good, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(outside) })
+
+	entry := func(name string) ManifestEntry {
+		return ManifestEntry{CRC: crc32.ChecksumIEEE(good), Size: uint32(len(good)), Name: name}
+	}
+	if errs := (Manifest{entry("good.jg")}).Validate(dir); len(errs) != 0 {
+		t.Errorf("valid entry reported %v", errs)
+	}
+
+	for _, name := range []string{"bad.jg", "missing.jg", "../outside.jg", "sub/good.jg", `sub\good.jg`, ".."} {
+		errs := (Manifest{entry(name)}).Validate(dir)
+		if len(errs) == 0 {
+			t.Errorf("Validate accepted %q", name)
+			continue
+		}
+		if !strings.HasPrefix(errs[0].Error(), name+":") {
+			t.Errorf("error for %q does not name the entry: %v", name, errs[0])
+		}
+	}
+
+	// 每个有问题的条目都会报告
+	m := Manifest{entry("good.jg"), entry("bad.jg"), entry("../outside.jg")}
+	if errs := m.Validate(dir); len(errs) != 2 {
+		t.Errorf("got %d errors, want 2: %v", len(errs), errs)
+	}
+}
+
+func TestManifestBytesQuotes(t *testing.T) {
+	m := Manifest{{Name: `a "quoted" name.jg`, Version: "1"}}
+	again, err := ParseManifest(m.Bytes())
+	if err != nil || len(again) != 1 || again[0] != m[0] {
+		t.Errorf("round trip of %q gave %v, %v", m[0].Name, again, err)
+	}
+	if !bytes.HasPrefix(m.Bytes(), []byte("{ crc: 0, size: 0, ")) {
+		t.Errorf("unexpected layout %q", m.Bytes())
+	}
+}
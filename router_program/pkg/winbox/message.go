@@ -0,0 +1,951 @@
+package winbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	kBool         = 0
+	kShortLength  = 0x01000000
+	kU32          = 0x08000000
+	kU64          = 0x10000000
+	kIp6          = 0x18000000
+	kString       = 0x20000000
+	kMessage      = 0x28000000
+	kRaw          = 0x30000000
+	kBoolArray    = 0x80000000
+	kU32Array     = 0x88000000
+	kU64Array     = 0x90000000
+	kIp6Array     = 0x98000000
+	kStringArray  = 0xa0000000
+	kMessageArray = 0xa8000000
+	kRawArray     = 0xb0000000
+)
+
+const (
+	SysTo         = 0x00ff0001
+	From          = 0x00ff0002
+	Seq           = 0x00ff0003
+	ReplyExpected = 0x00ff0005
+	RequestId     = 0x00ff0006
+	Command       = 0x00ff0007
+	ErrorCode     = 0x00ff0008
+	ErrorString   = 0x00ff0009
+	SessionId     = 0x00fe0001
+)
+
+const (
+	kNotImplemented   = 0x00fe0002
+	kNotImplementedv2 = 0x00fe0003
+	kObjNonexistant   = 0x00fe0004
+	kNotPermitted     = 0x00fe0009
+	kTimeout          = 0x00fe000d
+	kObjNonexistant2  = 0x00fe0011
+	kBusy             = 0x00fe0012
+)
+
+type Message struct {
+	bools       map[uint32]bool
+	u32s        map[uint32]uint32
+	u64s        map[uint32]uint64
+	ip6s        map[uint32][16]byte
+	strings     map[uint32]string
+	msgs        map[uint32]Message
+	raw         map[uint32]string
+	boolArray   map[uint32][]bool
+	u32Array    map[uint32][]uint32
+	u64Array    map[uint32][]uint64
+	ip6Array    map[uint32][][16]byte
+	stringArray map[uint32][]string
+	msgArray    map[uint32][]Message
+	rawArray    map[uint32][]string
+}
+
+func NewMessage() *Message {
+	return &Message{
+		bools:       make(map[uint32]bool),
+		u32s:        make(map[uint32]uint32),
+		u64s:        make(map[uint32]uint64),
+		ip6s:        make(map[uint32][16]byte),
+		strings:     make(map[uint32]string),
+		msgs:        make(map[uint32]Message),
+		raw:         make(map[uint32]string),
+		boolArray:   make(map[uint32][]bool),
+		u32Array:    make(map[uint32][]uint32),
+		u64Array:    make(map[uint32][]uint64),
+		ip6Array:    make(map[uint32][][16]byte),
+		stringArray: make(map[uint32][]string),
+		msgArray:    make(map[uint32][]Message),
+		rawArray:    make(map[uint32][]string),
+	}
+}
+
+func (w *Message) Reset() {
+	w.bools = make(map[uint32]bool)
+	w.u32s = make(map[uint32]uint32)
+	w.u64s = make(map[uint32]uint64)
+	w.ip6s = make(map[uint32][16]byte)
+	w.strings = make(map[uint32]string)
+	w.msgs = make(map[uint32]Message)
+	w.raw = make(map[uint32]string)
+	w.boolArray = make(map[uint32][]bool)
+	w.u32Array = make(map[uint32][]uint32)
+	w.u64Array = make(map[uint32][]uint64)
+	w.ip6Array = make(map[uint32][][16]byte)
+	w.stringArray = make(map[uint32][]string)
+	w.msgArray = make(map[uint32][]Message)
+	w.rawArray = make(map[uint32][]string)
+}
+
+func (w *Message) SerializeToBinary() string {
+	var returnVal string
+
+	for _, k := range sortedKeys(w.bools) {
+		v := w.bools[k]
+		command := make([]byte, 4)
+		typeVal := k
+		if v {
+			typeVal |= kShortLength
+		}
+		binary.LittleEndian.PutUint32(command, typeVal)
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.u32s) {
+		v := w.u32s[k]
+		var command []byte
+		typeVal := kU32 | k
+		value := v
+
+		if value > 255 {
+			// two byte length
+			command = make([]byte, 8)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			binary.LittleEndian.PutUint32(command[4:], value)
+		} else {
+			// one byte length
+			typeVal |= kShortLength
+			command = make([]byte, 5)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			command[4] = byte(value & 0xff)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.u64s) {
+		v := w.u64s[k]
+		command := make([]byte, 12)
+		typeVal := kU64 | k
+		value := v
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint64(command[4:], value)
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.ip6s) {
+		v := w.ip6s[k]
+		command := make([]byte, 20)
+		typeVal := kIp6 | k
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		copy(command[4:], v[:])
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.strings) {
+		v := w.strings[k]
+		typeVal := kString | k
+		command := make([]byte, 5)
+
+		if len(v) > 255 {
+			// two byte length
+			length := len(v)
+			command = make([]byte, 6)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			binary.LittleEndian.PutUint16(command[4:], uint16(length))
+			command = append(command, []byte(v)...)
+		} else {
+			// one byte length
+			typeVal |= kShortLength
+			length := len(v)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			command[4] = byte(length)
+			command = append(command, []byte(v)...)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.msgs) {
+		v := w.msgs[k]
+		typeVal := kMessage | k
+		command := make([]byte, 5)
+		serialized := "M2" + v.SerializeToBinary()
+
+		if len(serialized) > 255 {
+			// two byte length
+			length := len(serialized)
+			command = make([]byte, 6)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			binary.LittleEndian.PutUint16(command[4:], uint16(length))
+			command = append(command, []byte(serialized)...)
+		} else {
+			// one byte length
+			typeVal |= kShortLength
+			length := len(serialized)
+			binary.LittleEndian.PutUint32(command, typeVal)
+			command[4] = byte(length)
+			command = append(command, []byte(serialized)...)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.raw) {
+		v := w.raw[k]
+		var command bytes.Buffer
+		var typeVal uint32 = kRaw | k
+
+		if len(v) > 255 {
+			// two byte length
+			var length uint16 = uint16(len(v))
+			binary.Write(&command, binary.LittleEndian, typeVal)
+			binary.Write(&command, binary.LittleEndian, length)
+			command.WriteString(v)
+		} else {
+			// one byte length
+			typeVal |= kShortLength
+			var length uint8 = uint8(len(v))
+			binary.Write(&command, binary.LittleEndian, typeVal)
+			command.WriteByte(length)
+			command.WriteString(v)
+		}
+		returnVal += command.String()
+	}
+
+	for _, k := range sortedKeys(w.boolArray) {
+		v := w.boolArray[k]
+		typeVal := kBoolArray | k
+		arraySize := len(v)
+		var command bytes.Buffer
+		binary.Write(&command, binary.LittleEndian, typeVal)
+		binary.Write(&command, binary.LittleEndian, uint16(arraySize))
+		for _, value := range v {
+			binary.Write(&command, binary.LittleEndian, value)
+		}
+
+		returnVal += command.String()
+	}
+
+	for _, k := range sortedKeys(w.u32Array) {
+		v := w.u32Array[k]
+		typeVal := kU32Array | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+		for i := 0; i < arraySize; i++ {
+			command = binary.LittleEndian.AppendUint32(command, v[i])
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.u64Array) {
+		v := w.u64Array[k]
+		typeVal := kU64Array | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+		for i := 0; i < arraySize; i++ {
+			command = binary.LittleEndian.AppendUint64(command, v[i])
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.ip6Array) {
+		v := w.ip6Array[k]
+		typeVal := kIp6Array | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+		for i := 0; i < arraySize; i++ {
+			command = append(command, v[i][:]...)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.stringArray) {
+		v := w.stringArray[k]
+		typeVal := kStringArray | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+
+		for i := 0; i < arraySize; i++ {
+			length := len(v[i])
+			command = append(command, make([]byte, 2)...)
+			binary.LittleEndian.PutUint16(command[len(command)-2:], uint16(length))
+			command = append(command, []byte(v[i])...)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.msgArray) {
+		v := w.msgArray[k]
+		typeVal := kMessageArray | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+
+		for i := 0; i < arraySize; i++ {
+			tempMsg := v[i]
+			tempString := "M2" + tempMsg.SerializeToBinary()
+
+			length := len(tempString)
+			command = append(command, make([]byte, 2)...)
+			binary.LittleEndian.PutUint16(command[len(command)-2:], uint16(length))
+			command = append(command, []byte(tempString)...)
+		}
+
+		returnVal += string(command)
+	}
+
+	for _, k := range sortedKeys(w.rawArray) {
+		v := w.rawArray[k]
+		typeVal := kRawArray | k
+		arraySize := len(v)
+		command := make([]byte, 6)
+
+		binary.LittleEndian.PutUint32(command, typeVal)
+		binary.LittleEndian.PutUint16(command[4:], uint16(arraySize))
+
+		for i := 0; i < arraySize; i++ {
+			length := len(v[i])
+			command = append(command, make([]byte, 2)...)
+			binary.LittleEndian.PutUint16(command[len(command)-2:], uint16(length))
+			command = append(command, []byte(v[i])...)
+		}
+
+		returnVal += string(command)
+	}
+
+	return returnVal
+}
+
+func (w *Message) SerializeToJson() string {
+	var b strings.Builder
+	w.writeJson(&b)
+	return b.String()
+}
+
+// writeJson 把文本格式写入 b, 嵌套的消息写入同一个 b, 避免反复拼接字符串
+func (w *Message) writeJson(b *strings.Builder) {
+	b.WriteByte('{')
+
+	first := true
+	field := func(prefix byte, k uint32) {
+		if !first {
+			b.WriteByte(',')
+		} else {
+			first = false
+		}
+		b.WriteByte(prefix)
+		b.WriteString(strconv.FormatUint(uint64(k), 16))
+		b.WriteByte(':')
+	}
+
+	for _, k := range sortedKeys(w.bools) {
+		field('b', k)
+		b.WriteString(strconv.FormatBool(w.bools[k]))
+	}
+
+	for _, k := range sortedKeys(w.u32s) {
+		field('u', k)
+		b.WriteString(strconv.FormatUint(uint64(w.u32s[k]), 10))
+	}
+
+	for _, k := range sortedKeys(w.u64s) {
+		field('q', k)
+		b.WriteString(strconv.FormatUint(w.u64s[k], 10))
+	}
+
+	for _, k := range sortedKeys(w.ip6s) {
+		field('a', k)
+		b.WriteString(netip.AddrFrom16(w.ip6s[k]).String())
+	}
+
+	for _, k := range sortedKeys(w.strings) {
+		field('s', k)
+		b.WriteString(quoteText(w.strings[k]))
+	}
+
+	for _, k := range sortedKeys(w.raw) {
+		field('r', k)
+		writeBytes(b, w.raw[k])
+	}
+
+	for _, k := range sortedKeys(w.msgs) {
+		field('m', k)
+		v := w.msgs[k]
+		v.writeJson(b)
+	}
+
+	for _, k := range sortedKeys(w.boolArray) {
+		field('B', k)
+		b.WriteByte('[')
+		for i, v := range w.boolArray[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.FormatBool(v))
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.u32Array) {
+		field('U', k)
+		b.WriteByte('[')
+		for i, v := range w.u32Array[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.FormatUint(uint64(v), 10))
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.u64Array) {
+		field('Q', k)
+		b.WriteByte('[')
+		for i, v := range w.u64Array[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.FormatUint(v, 10))
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.ip6Array) {
+		field('A', k)
+		b.WriteByte('[')
+		for i, v := range w.ip6Array[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(netip.AddrFrom16(v).String())
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.stringArray) {
+		field('S', k)
+		b.WriteByte('[')
+		for i, v := range w.stringArray[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quoteText(v))
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.msgArray) {
+		field('M', k)
+		b.WriteByte('[')
+		for i, v := range w.msgArray[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			v.writeJson(b)
+		}
+		b.WriteByte(']')
+	}
+
+	for _, k := range sortedKeys(w.rawArray) {
+		field('R', k)
+		b.WriteByte('[')
+		for i, v := range w.rawArray[k] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeBytes(b, v)
+		}
+		b.WriteByte(']')
+	}
+
+	b.WriteByte('}')
+}
+
+// writeBytes 把原始数据写成 [1,2,3] 的形式
+func writeBytes(b *strings.Builder, v string) {
+	b.WriteByte('[')
+	for i := 0; i < len(v); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(int(v[i])))
+	}
+	b.WriteByte(']')
+}
+
+func (msg *Message) ParseBinary(pInput []byte) bool {
+	input := make([]byte, len(pInput))
+	copy(input, pInput)
+
+	if len(input) > 2 && bytes.Compare(input[:2], []byte("M2")) == 0 {
+		input = input[2:]
+	}
+
+	for len(input) >= 4 {
+		typeName := binary.LittleEndian.Uint32(input[:4])
+		typeVal := typeName & 0xf8000000
+		name := typeName & 0x00ffffff
+		input = input[4:]
+
+		switch typeVal {
+		case kBool:
+			msg.bools[name] = (typeName & kShortLength) != 0
+		case kU32:
+			if typeName&kShortLength != 0 && len(input) > 0 {
+				msg.u32s[name] = uint32(input[0] & 0xff)
+				input = input[1:]
+			} else if len(input) >= 4 {
+				value := binary.LittleEndian.Uint32(input[:4])
+				msg.u32s[name] = value
+				input = input[4:]
+			}
+		case kU64:
+			if len(input) >= 8 {
+				value := binary.LittleEndian.Uint64(input[:8])
+				msg.u64s[name] = value
+				input = input[8:]
+			}
+		case kIp6:
+			if len(input) >= 16 {
+				var value [16]byte
+				copy(value[:], input[:16])
+				msg.ip6s[name] = value
+				input = input[16:]
+			}
+		case kRaw, kString:
+			// 短长度只需要一个字节, 否则最后一个字段为空字符串时会被丢掉
+			if typeName&kShortLength != 0 && len(input) >= 1 || len(input) >= 2 {
+				length := uint16(input[0] & 0xff)
+				if typeName&kShortLength != 0 {
+					input = input[1:]
+				} else {
+					length = binary.LittleEndian.Uint16(input[:2])
+					input = input[2:]
+				}
+
+				if len(input) >= int(length) {
+					value := string(input[:length])
+					if typeVal == kRaw {
+						msg.raw[name] = value
+					} else {
+						msg.strings[name] = value
+					}
+					input = input[length:]
+				} else {
+					if typeVal == kRaw {
+						msg.raw[name] = string(input)
+					} else {
+						msg.strings[name] = string(input)
+					}
+					input = nil
+				}
+			}
+		case kMessage:
+			if typeName&kShortLength != 0 && len(input) >= 1 || len(input) >= 2 {
+				length := uint16(input[0] & 0xff)
+				if typeName&kShortLength != 0 {
+					input = input[1:]
+				} else {
+					length = binary.LittleEndian.Uint16(input[:2])
+					input = input[2:]
+				}
+
+				if len(input) >= int(length) {
+					value := string(input[:length])
+					if len(value) >= 2 && value[0] == 'M' && value[1] == '2' {
+						value = value[2:]
+						temp := NewMessage()
+						temp.ParseBinary([]byte(value))
+						msg.msgs[name] = *temp
+						input = input[length:]
+					}
+				} else if len(input) >= 2 && input[0] == 'M' && input[1] == '2' {
+					input = input[2:]
+					temp := NewMessage()
+					temp.ParseBinary(input)
+					msg.msgs[name] = *temp
+					input = nil
+				}
+			}
+		// 数组的元素个数来自输入, 长度足够时才分配和保存, 避免很短的报文声明大量元素
+		case kBoolArray:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				if len(input) >= entries {
+					bools := make([]bool, entries)
+					for i := range bools {
+						bools[i] = input[i] == 1
+					}
+					input = input[entries:]
+					msg.boolArray[name] = bools
+				}
+			}
+		case kU32Array:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				if len(input) >= entries*4 {
+					u32s := make([]uint32, entries)
+					for i := range u32s {
+						u32s[i] = binary.LittleEndian.Uint32(input[i*4 : (i+1)*4])
+					}
+					input = input[entries*4:]
+					msg.u32Array[name] = u32s
+				}
+			}
+		case kU64Array:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				if len(input) >= entries*8 {
+					u64s := make([]uint64, entries)
+					for i := range u64s {
+						u64s[i] = binary.LittleEndian.Uint64(input[i*8 : (i+1)*8])
+					}
+					input = input[entries*8:]
+					msg.u64Array[name] = u64s
+				}
+			}
+		case kIp6Array:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				if len(input) >= entries*16 {
+					ip6s := make([][16]byte, entries)
+					for i := range ip6s {
+						copy(ip6s[i][:], input[i*16:(i+1)*16])
+					}
+					input = input[entries*16:]
+					msg.ip6Array[name] = ip6s
+				}
+			}
+		case kRawArray, kStringArray:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				// 每个元素至少有两个字节的长度, 只保存完整读出的元素
+				if len(input) >= entries*2 {
+					strings := make([]string, 0, entries)
+					consumed := 0
+					for len(strings) < entries && consumed+2 <= len(input) {
+						length := int(binary.LittleEndian.Uint16(input[consumed : consumed+2]))
+						if consumed+2+length > len(input) {
+							break
+						}
+						strings = append(strings, string(input[consumed+2:consumed+2+length]))
+						consumed += 2 + length
+					}
+					input = input[consumed:]
+					if typeVal == kRawArray {
+						msg.rawArray[name] = strings
+					} else {
+						msg.stringArray[name] = strings
+					}
+				}
+			}
+		case kMessageArray:
+			if len(input) >= 2 {
+				entries := int(binary.LittleEndian.Uint16(input[:2]))
+				input = input[2:]
+
+				// 每个元素至少有两个字节的长度和 M2, 只保存完整读出的元素
+				if len(input) >= entries*4 {
+					msgs := make([]Message, 0, entries)
+					consumed := 0
+					for len(msgs) < entries && consumed+2 <= len(input) {
+						length := int(binary.LittleEndian.Uint16(input[consumed : consumed+2]))
+						value := input[consumed+2:]
+						if length > len(value) || length < 2 || value[0] != 'M' || value[1] != '2' {
+							break
+						}
+						tempMessage := NewMessage()
+						tempMessage.ParseBinary(value[2:length])
+						msgs = append(msgs, *tempMessage)
+						consumed += 2 + length
+					}
+					input = input[consumed:]
+					msg.msgArray[name] = msgs
+				}
+			}
+		default:
+			//fmt.Printf("Parsing error: %x\n", typeVal&0xff)
+		}
+	}
+	return true
+}
+
+func (w *Message) HasError() bool {
+	_, strExists := w.strings[ErrorString]
+	_, u32Exists := w.u32s[ErrorCode]
+	return strExists || u32Exists
+}
+
+func (w *Message) ErrorString() string {
+	if w.HasError() {
+		if str, exists := w.strings[ErrorString]; exists {
+			return str
+		} else if code, exists := w.u32s[ErrorCode]; exists {
+			switch code {
+			case kNotImplemented, kNotImplementedv2:
+				return "Feature not implemented"
+			case kObjNonexistant, kObjNonexistant2:
+				return "Object doesn't exist"
+			case kNotPermitted:
+				return "Not permitted"
+			case kTimeout:
+				return "Timeout"
+			case kBusy:
+				return "Busy"
+			default:
+				return "Unknown error code"
+			}
+		}
+	}
+	return ""
+}
+
+func (w *Message) GetSessionID() uint32 {
+	return w.GetU32(SessionId)
+}
+
+func (w *Message) GetBoolean(pName uint32) bool {
+	if val, exists := w.bools[pName]; exists {
+		return val
+	}
+	return false
+}
+
+func (w *Message) GetU32(pName uint32) uint32 {
+	if val, exists := w.u32s[pName]; exists {
+		return val
+	}
+	return 0
+}
+
+func (w *Message) GetU64(pName uint32) uint64 {
+	if val, exists := w.u64s[pName]; exists {
+		return val
+	}
+	return 0
+}
+
+func (w *Message) GetIP6(pName uint32) [16]byte {
+	if val, exists := w.ip6s[pName]; exists {
+		return val
+	}
+	return [16]byte{}
+}
+
+func (w *Message) GetRaw(pName uint32) string {
+	if val, exists := w.raw[pName]; exists {
+		return val
+	}
+	return ""
+}
+
+func (w *Message) GetString(pName uint32) string {
+	if val, exists := w.strings[pName]; exists {
+		return val
+	}
+	return ""
+}
+
+func (w *Message) GetMsg(pName uint32) Message {
+	if val, exists := w.msgs[pName]; exists {
+		return val
+	}
+	return *NewMessage()
+}
+
+func (w *Message) GetBooleanArray(pName uint32) []bool {
+	if val, exists := w.boolArray[pName]; exists {
+		return val
+	}
+	return []bool{}
+}
+
+func (w *Message) GetU32Array(pName uint32) []uint32 {
+	if val, exists := w.u32Array[pName]; exists {
+		return val
+	}
+	return []uint32{}
+}
+
+func (w *Message) GetU64Array(pName uint32) []uint64 {
+	if val, exists := w.u64Array[pName]; exists {
+		return val
+	}
+	return []uint64{}
+}
+
+func (w *Message) GetIP6Array(pName uint32) [][16]byte {
+	if val, exists := w.ip6Array[pName]; exists {
+		return val
+	}
+	return [][16]byte{}
+}
+
+func (w *Message) GetStringArray(pName uint32) []string {
+	if val, exists := w.stringArray[pName]; exists {
+		return val
+	}
+	return []string{}
+}
+
+func (w *Message) GetMsgArray(pName uint32) []Message {
+	if val, exists := w.msgArray[pName]; exists {
+		return val
+	}
+	return []Message{}
+}
+
+func (w *Message) GetRawArray(pName uint32) []string {
+	if val, exists := w.rawArray[pName]; exists {
+		return val
+	}
+	return []string{}
+}
+
+func (w *Message) SetTo(pTo uint32) {
+	delete(w.u32Array, SysTo)
+
+	to := []uint32{pTo}
+	w.AddU32Array(SysTo, to)
+}
+
+func (w *Message) SetToWithHandler(pTo, pHandler uint32) {
+	delete(w.u32Array, SysTo)
+
+	to := []uint32{pTo, pHandler}
+	w.AddU32Array(SysTo, to)
+}
+
+func (w *Message) SetCommand(pCommand uint32) {
+	w.AddU32(Command, pCommand)
+}
+
+func (w *Message) SetReplyExpected(pReplyExpected bool) {
+	w.AddBoolean(ReplyExpected, pReplyExpected)
+}
+
+func (w *Message) SetRequestID(pID uint32) {
+	w.AddU32(RequestId, pID)
+}
+
+func (w *Message) SetSessionID(pSessionID uint32) {
+	w.AddU32(SessionId, pSessionID)
+}
+
+func (w *Message) AddBoolean(pName uint32, pValue bool) {
+	w.bools[pName] = pValue
+}
+
+func (w *Message) AddU32(pName, pValue uint32) {
+	w.u32s[pName] = pValue
+}
+
+func (w *Message) AddU64(pName uint32, pValue uint64) {
+	w.u64s[pName] = pValue
+}
+
+func (w *Message) AddIP6(pName uint32, pValue [16]byte) {
+	w.ip6s[pName] = pValue
+}
+
+func (w *Message) AddString(pName uint32, pString string) {
+	w.strings[pName] = pString
+}
+
+func (w *Message) AddMsg(pName uint32, pMsg Message) {
+	w.msgs[pName] = pMsg
+}
+
+func (w *Message) AddRaw(pName uint32, pRaw string) {
+	w.raw[pName] = pRaw
+}
+
+func (w *Message) AddBooleanArray(pName uint32, pValue []bool) {
+	w.boolArray[pName] = pValue
+}
+
+func (w *Message) AddU32Array(pName uint32, pValue []uint32) {
+	w.u32Array[pName] = pValue
+}
+
+func (w *Message) AddU64Array(pName uint32, pValue []uint64) {
+	w.u64Array[pName] = pValue
+}
+
+func (w *Message) AddIP6Array(pName uint32, pValue [][16]byte) {
+	w.ip6Array[pName] = pValue
+}
+
+func (w *Message) AddStringArray(pName uint32, pValue []string) {
+	w.stringArray[pName] = pValue
+}
+
+func (w *Message) AddMsgArray(pName uint32, pValue []Message) {
+	w.msgArray[pName] = pValue
+}
+
+func (w *Message) AddRawArray(pName uint32, pValue []string) {
+	w.rawArray[pName] = pValue
+}
+
+func (w *Message) EraseU32(pName uint32) {
+	delete(w.u32s, pName)
+}
+
+// sortedKeys 保证序列化结果的字段顺序稳定
+func sortedKeys[V any](m map[uint32]V) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package winbox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sampleMessage() *Message {
+	inner := NewMessage()
+	inner.AddU32(1, 7)
+	inner.AddString(2, "inner")
+
+	msg := NewMessage()
+	msg.AddBoolean(0x01, true)
+	msg.AddBoolean(0x02, false)
+	msg.AddU32(0x03, 200)   // 短格式
+	msg.AddU32(0x04, 70000) // 长格式
+	msg.AddU64(0x05, 1<<40)
+	msg.AddIP6(0x06, [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1})
+	msg.AddString(0x07, "hello, 'world'")
+	msg.AddString(0x08, strings.Repeat("x", 300)) // 长格式的长度
+	msg.AddRaw(0x09, "\x00\x01\xff")
+	msg.AddMsg(0x0a, *inner)
+	msg.AddBooleanArray(0x0b, []bool{true, false, true})
+	msg.AddU32Array(SysTo, []uint32{13, 4})
+	msg.AddU64Array(0x0c, []uint64{1, 1 << 63})
+	msg.AddIP6Array(0x0d, [][16]byte{{1}, {2}})
+	msg.AddStringArray(0x0e, []string{"a", "", "c"})
+	msg.AddMsgArray(0x0f, []Message{*inner, *inner})
+	msg.AddRawArray(0x10, []string{"\x00", "\xfe\xff"})
+	msg.AddU32(RequestId, 9)
+	return msg
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	msg := sampleMessage()
+	decoded := Decode(msg.Marshal())
+
+	if got, want := decoded.SerializeToJson(), msg.SerializeToJson(); got != want {
+		t.Fatalf("round trip changed the message:\n got %s\nwant %s", got, want)
+	}
+	if decoded.GetU32(0x04) != 70000 || decoded.GetU64(0x05) != 1<<40 {
+		t.Errorf("numbers changed")
+	}
+	if decoded.GetString(0x08) != strings.Repeat("x", 300) || decoded.GetRaw(0x09) != "\x00\x01\xff" {
+		t.Errorf("strings changed")
+	}
+	if inner := decoded.GetMsg(0x0a); inner.GetString(2) != "inner" {
+		t.Errorf("nested message changed")
+	}
+	if !slices.Equal(decoded.GetU32Array(SysTo), []uint32{13, 4}) || !slices.Equal(decoded.GetRawArray(0x10), []string{"\x00", "\xfe\xff"}) {
+		t.Errorf("arrays changed")
+	}
+	// 再次编码得到相同的字节
+	if string(decoded.Marshal()) != string(msg.Marshal()) {
+		t.Errorf("re-encoding produced different bytes")
+	}
+}
+
+func TestBinaryWithoutMagic(t *testing.T) {
+	msg := sampleMessage()
+	decoded := NewMessage()
+	decoded.ParseBinary([]byte(msg.SerializeToBinary()))
+	if decoded.SerializeToJson() != msg.SerializeToJson() {
+		t.Fatal("message without the M2 prefix decoded differently")
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	data := sampleMessage().Marshal()
+	for i := range data {
+		// 截断的消息不能导致 panic
+		Decode(data[:i])
+	}
+}
+
+func TestBinaryEmptyShortString(t *testing.T) {
+	// 空字符串使用短格式, 作为最后一个字段时只有一个字节的长度
+	only := NewMessage()
+	only.AddString(0, "")
+	both := NewMessage()
+	both.AddString(0, "")
+	both.AddRaw(1, "")
+	for _, msg := range []*Message{only, both} {
+		if got, want := Decode(msg.Marshal()).SerializeToJson(), msg.SerializeToJson(); got != want {
+			t.Errorf("round trip of %s gave %s", want, got)
+		}
+	}
+	if got := only.SerializeToJson(); got != "{s0:''}" {
+		t.Errorf("got %s, want {s0:''}", got)
+	}
+}
+
+func TestBinaryShortArray(t *testing.T) {
+	// 只有类型和元素个数, 声明了 65535 个元素
+	for _, typ := range []byte{0x80, 0x88, 0x90, 0x98, 0xa0, 0xa8, 0xb0} {
+		data := []byte{'M', '2', 0x01, 0x00, 0x00, typ, 0xff, 0xff}
+		msg := Decode(data)
+		if text := msg.SerializeToJson(); text != "{}" {
+			t.Errorf("array type %#x with missing entries decoded as %.40s...", typ, text)
+		}
+	}
+
+	// 声明 3 个字符串但只有 2 个时只保存读出的元素
+	data := []byte{'M', '2', 0x01, 0x00, 0x00, 0xa0, 3, 0, 1, 0, 'a', 1, 0, 'b'}
+	if got := Decode(data).GetStringArray(1); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("truncated string array decoded as %q", got)
+	}
+}
+
+func TestSerializeLargeArray(t *testing.T) {
+	// 65535 个空的原始数据, 逐段拼接字符串时要几秒钟
+	msg := NewMessage()
+	msg.AddRawArray(1, make([]string, 0xffff))
+	want := "{R1:[" + strings.Repeat("[],", 0xfffe) + "[]]}"
+	if got := Decode(msg.Marshal()).SerializeToJson(); got != want {
+		t.Errorf("got %d bytes of text, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,319 @@
+package winbox
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// 文本格式与 SerializeToJson 的输出一致, 例如
+// {u2:188,ufe0001:1,s1:'list',Uff0001:[2,2],m5:{b1:true}}
+// 类型前缀: b u q a s r m 为单值, B U Q A S M R 为数组
+// 字符串中的 \ 和 ' 转义为 \\ 和 \'
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// quoteText 把字符串格式化为 '...'
+func quoteText(s string) string {
+	return "'" + textEscaper.Replace(s) + "'"
+}
+
+// ParseJSON 解析文本格式的消息并合并到 w 中
+func (w *Message) ParseJSON(pInput string) bool {
+	p := textParser{input: pInput}
+	return p.parse(w) == nil
+}
+
+// ParseText 解析文本格式的消息, 出错时返回出错位置
+func ParseText(input string) (*Message, error) {
+	msg := NewMessage()
+	p := textParser{input: input}
+	if err := p.parse(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+type textParser struct {
+	input string
+	pos   int
+}
+
+func (p *textParser) parse(w *Message) error {
+	if err := p.message(w); err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos != len(p.input) {
+		return p.errorf("unexpected trailing input")
+	}
+	return nil
+}
+
+func (p *textParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *textParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *textParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// token 读取到下一个分隔符为止的内容
+func (p *textParser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte(",]}: \t\r\n", p.input[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *textParser) message(w *Message) error {
+	if err := p.expect('{'); err != nil {
+		return err
+	}
+	if p.peek() == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		if err := p.field(w); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *textParser) field(w *Message) error {
+	typeChar := p.peek()
+	if typeChar == 0 {
+		return p.errorf("unexpected end of input")
+	}
+	p.pos++
+	nameString := p.token()
+	name, err := strconv.ParseUint(nameString, 16, 24)
+	if err != nil {
+		return p.errorf("bad field name %q", nameString)
+	}
+	if err := p.expect(':'); err != nil {
+		return err
+	}
+	variable := uint32(name)
+
+	switch typeChar {
+	case 'b':
+		v, err := p.boolean()
+		if err != nil {
+			return err
+		}
+		w.bools[variable] = v
+	case 'u':
+		v, err := p.unsigned(32)
+		if err != nil {
+			return err
+		}
+		w.u32s[variable] = uint32(v)
+	case 'q':
+		v, err := p.unsigned(64)
+		if err != nil {
+			return err
+		}
+		w.u64s[variable] = v
+	case 'a':
+		v, err := p.ip6()
+		if err != nil {
+			return err
+		}
+		w.ip6s[variable] = v
+	case 's':
+		v, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		w.strings[variable] = v
+	case 'r':
+		v, err := p.raw()
+		if err != nil {
+			return err
+		}
+		w.raw[variable] = v
+	case 'm':
+		v := NewMessage()
+		if err := p.message(v); err != nil {
+			return err
+		}
+		w.msgs[variable] = *v
+	case 'B':
+		return p.array(func() error {
+			v, err := p.boolean()
+			w.boolArray[variable] = append(w.boolArray[variable], v)
+			return err
+		}, func() { w.boolArray[variable] = []bool{} })
+	case 'U':
+		return p.array(func() error {
+			v, err := p.unsigned(32)
+			w.u32Array[variable] = append(w.u32Array[variable], uint32(v))
+			return err
+		}, func() { w.u32Array[variable] = []uint32{} })
+	case 'Q':
+		return p.array(func() error {
+			v, err := p.unsigned(64)
+			w.u64Array[variable] = append(w.u64Array[variable], v)
+			return err
+		}, func() { w.u64Array[variable] = []uint64{} })
+	case 'A':
+		return p.array(func() error {
+			v, err := p.ip6()
+			w.ip6Array[variable] = append(w.ip6Array[variable], v)
+			return err
+		}, func() { w.ip6Array[variable] = [][16]byte{} })
+	case 'S':
+		return p.array(func() error {
+			v, err := p.quoted()
+			w.stringArray[variable] = append(w.stringArray[variable], v)
+			return err
+		}, func() { w.stringArray[variable] = []string{} })
+	case 'M':
+		return p.array(func() error {
+			v := NewMessage()
+			err := p.message(v)
+			w.msgArray[variable] = append(w.msgArray[variable], *v)
+			return err
+		}, func() { w.msgArray[variable] = []Message{} })
+	case 'R':
+		return p.array(func() error {
+			v, err := p.raw()
+			w.rawArray[variable] = append(w.rawArray[variable], v)
+			return err
+		}, func() { w.rawArray[variable] = []string{} })
+	default:
+		return p.errorf("unknown type %q", typeChar)
+	}
+	return nil
+}
+
+// array 解析 [elem,elem,...], init 用于保留空数组
+func (p *textParser) array(elem func() error, init func()) error {
+	if err := p.expect('['); err != nil {
+		return err
+	}
+	init()
+	if p.peek() == ']' {
+		p.pos++
+		return nil
+	}
+	for {
+		if err := elem(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *textParser) boolean() (bool, error) {
+	switch v := p.token(); v {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	default:
+		return false, p.errorf("bad boolean %q", v)
+	}
+}
+
+// unsigned 支持十进制和 0x 开头的十六进制
+func (p *textParser) unsigned(bits int) (uint64, error) {
+	v := p.token()
+	value, err := strconv.ParseUint(v, 0, bits)
+	if err != nil {
+		return 0, p.errorf("bad number %q", v)
+	}
+	return value, nil
+}
+
+func (p *textParser) ip6() ([16]byte, error) {
+	p.skipSpace()
+	start := p.pos
+	// IPv6 地址本身含有冒号, 读到 , ] } 为止
+	for p.pos < len(p.input) && strings.IndexByte(",]} \t\r\n", p.input[p.pos]) < 0 {
+		p.pos++
+	}
+	v := p.input[start:p.pos]
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return [16]byte{}, p.errorf("bad address %q", v)
+	}
+	return addr.As16(), nil
+}
+
+// quoted 解析 '...', 字符串以后面紧跟 , ] } 或输入结尾的单引号结束
+func (p *textParser) quoted() (string, error) {
+	if err := p.expect('\''); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := p.pos; i < len(p.input); i++ {
+		c := p.input[i]
+		switch {
+		case c == '\\' && i+1 < len(p.input) && (p.input[i+1] == '\\' || p.input[i+1] == '\''):
+			b.WriteByte(p.input[i+1])
+			i++
+		case c == '\'':
+			rest := strings.TrimLeft(p.input[i+1:], " \t\r\n")
+			if rest == "" || strings.IndexByte(",]}", rest[0]) >= 0 {
+				p.pos = i + 1
+				return b.String(), nil
+			}
+			// 兼容手写的未转义单引号
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *textParser) raw() (string, error) {
+	var raw []byte
+	err := p.array(func() error {
+		v, err := p.unsigned(8)
+		raw = append(raw, byte(v))
+		return err
+	}, func() {})
+	return string(raw), err
+}
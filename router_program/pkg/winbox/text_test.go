@@ -0,0 +1,71 @@
+package winbox
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	msg := sampleMessage()
+	text := msg.SerializeToJson()
+	parsed, err := ParseText(text)
+	if err != nil {
+		t.Fatalf("ParseText(%s): %v", text, err)
+	}
+	if got := parsed.SerializeToJson(); got != text {
+		t.Fatalf("round trip changed the message:\n got %s\nwant %s", got, text)
+	}
+}
+
+func TestTextEscaping(t *testing.T) {
+	values := []string{
+		"a',b",
+		"x'}",
+		"y']",
+		`back\`,
+		`\'`,
+		`'`,
+		"it's",
+		"",
+	}
+	for _, v := range values {
+		msg := NewMessage()
+		msg.AddString(1, v)
+		msg.AddStringArray(2, []string{v, v})
+		msg.AddU32(3, 7)
+		text := msg.SerializeToJson()
+
+		parsed, err := ParseText(text)
+		if err != nil {
+			t.Fatalf("%q: ParseText(%s): %v", v, text, err)
+		}
+		if got := parsed.GetString(1); got != v {
+			t.Errorf("%q: string came back as %q from %s", v, got, text)
+		}
+		if got := parsed.GetStringArray(2); !slices.Equal(got, []string{v, v}) {
+			t.Errorf("%q: array came back as %q from %s", v, got, text)
+		}
+		if parsed.GetU32(3) != 7 {
+			t.Errorf("%q: following field lost in %s", v, text)
+		}
+	}
+}
+
+func TestTextUnescapedQuote(t *testing.T) {
+	// 手写的未转义单引号在后面不是分隔符时作为字符串内容
+	msg, err := ParseText("{s1:'it's',u2:1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetString(1) != "it's" || msg.GetU32(2) != 1 {
+		t.Fatalf("got %s", msg.SerializeToJson())
+	}
+}
+
+func TestTextErrors(t *testing.T) {
+	for _, text := range []string{"", "{", "{s1:'abc}", "{u1:x}", "{z1:1}", "{u1:1}x"} {
+		if _, err := ParseText(text); err == nil {
+			t.Errorf("ParseText(%q) succeeded", text)
+		}
+	}
+}